@@ -0,0 +1,104 @@
+package opencat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// AuditLogger receives a record of every mutating call made by a client set
+// up with WithAuditLogger: creating, updating, and deleting resources,
+// granting entitlements, submitting receipts, revalidating subscribers,
+// enabling webhooks, and acknowledging events. Reads, and checks that
+// persist nothing such as ReceiptGrantsEntitlement, are not logged. LogAudit
+// is called synchronously after each request completes, with the call's
+// context, which is where callers usually carry the identity of the acting
+// user; BulkDeleteSubscribers logs one entry per request it makes.
+type AuditLogger interface {
+	LogAudit(ctx context.Context, entry AuditEntry)
+}
+
+// AuditEntry describes one mutating call.
+type AuditEntry struct {
+	// Operation is the method name without the Context suffix, e.g.
+	// "CreateProduct".
+	Operation string
+	// ResourceIDs holds the IDs the call was made with, keyed by their JSON
+	// names, e.g. "app_id".
+	ResourceIDs map[string]string
+	// Body is the request body with receipts, secrets, and tokens replaced by
+	// "[REDACTED]".
+	Body map[string]any
+	// StatusCode is the HTTP status of the last attempt, or 0 if no response
+	// was received.
+	StatusCode int
+	Err        error
+	Time       time.Time
+}
+
+type auditInfo struct {
+	operation string
+	ids       map[string]string
+	status    int
+}
+
+var redactedFields = map[string]bool{
+	"secret":         true,
+	"receipt_data":   true,
+	"token":          true,
+	"api_key":        true,
+	"password":       true,
+	"custom_headers": true,
+}
+
+// audited tags opts with the operation, so request logs it once it is sent.
+func (c *Client) audited(operation string, ids map[string]string, opts []CallOption) []CallOption {
+	if c.auditLogger == nil {
+		return opts
+	}
+	return append([]CallOption{func(co *callOptions) {
+		co.audit = &auditInfo{operation: operation, ids: ids}
+	}}, opts...)
+}
+
+func (c *Client) logAudit(ctx context.Context, co *callOptions, payload []byte, err error) {
+	entry := AuditEntry{
+		Operation:   co.audit.operation,
+		ResourceIDs: co.audit.ids,
+		StatusCode:  co.audit.status,
+		Err:         err,
+		Time:        time.Now(),
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		entry.StatusCode = apiErr.StatusCode
+	}
+	if payload != nil {
+		var body map[string]any
+		if json.Unmarshal(payload, &body) == nil {
+			entry.Body = redact(body)
+		}
+	}
+	c.auditLogger.LogAudit(ctx, entry)
+}
+
+func redact(v map[string]any) map[string]any {
+	for k, field := range v {
+		if redactedFields[k] {
+			v[k] = "[REDACTED]"
+			continue
+		}
+		switch field := field.(type) {
+		case map[string]any:
+			redact(field)
+		case []any:
+			for _, item := range field {
+				if nested, ok := item.(map[string]any); ok {
+					redact(nested)
+				}
+			}
+		}
+	}
+	return v
+}
@@ -0,0 +1,228 @@
+package opencat
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingAuditLogger struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+}
+
+func (l *recordingAuditLogger) LogAudit(ctx context.Context, entry AuditEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+}
+
+func TestAuditLogger(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v1/apps":
+			w.Write([]byte(`[]`))
+		case "PATCH /v1/webhooks/wh-1":
+			w.Write([]byte(`{"id":"wh-1","active":true}`))
+		case "DELETE /v1/transactions/tx-1":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`not found`))
+		default:
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	})
+	defer srv.Close()
+	logger := &recordingAuditLogger{}
+	WithAuditLogger(logger)(c)
+
+	if _, err := c.ListApps(); err != nil {
+		t.Fatal(err)
+	}
+	update := WebhookUpdate{SigningAlgorithm: SigningSHA512, CustomHeaders: map[string]string{"Authorization": "Bearer s3cret"}}
+	if _, err := c.UpdateWebhook("wh-1", update); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteTransaction("tx-1"); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if len(logger.entries) != 2 {
+		t.Fatalf("expected only the 2 mutating calls to be logged, got %+v", logger.entries)
+	}
+	updated := logger.entries[0]
+	if updated.Operation != "UpdateWebhook" || updated.ResourceIDs["webhook_id"] != "wh-1" || updated.StatusCode != 200 {
+		t.Fatalf("unexpected entry %+v", updated)
+	}
+	if updated.Body["signing_algorithm"] != "sha512" || updated.Body["custom_headers"] != "[REDACTED]" {
+		t.Fatalf("unexpected body %v", updated.Body)
+	}
+	deleted := logger.entries[1]
+	if deleted.Operation != "DeleteTransaction" || deleted.StatusCode != 404 || deleted.Err == nil || deleted.Body != nil {
+		t.Fatalf("unexpected entry %+v", deleted)
+	}
+}
+
+// auditedCalls makes one call to every mutating method, and the operation
+// each is logged as.
+var auditedCalls = map[string]struct {
+	operation string
+	call      func(c *Client) error
+}{
+	"CreateApp": {"CreateApp", func(c *Client) error {
+		_, err := c.CreateApp("A", "ios", "com.a")
+		return err
+	}},
+	"DeleteApp": {"DeleteApp", func(c *Client) error { return c.DeleteApp("app-1") }},
+	"CreateAnonymousSubscriber": {"CreateAnonymousSubscriber", func(c *Client) error {
+		_, err := c.CreateAnonymousSubscriber("app-1")
+		return err
+	}},
+	"RevalidateSubscriber": {"RevalidateSubscriber", func(c *Client) error {
+		_, err := c.RevalidateSubscriber("user-1")
+		return err
+	}},
+	"SetSubscriberProfile": {"SetSubscriberProfile", func(c *Client) error {
+		return c.SetSubscriberProfile("user-1", SubscriberProfile{})
+	}},
+	"SetSubscriberAttribution": {"SetSubscriberAttribution", func(c *Client) error {
+		return c.SetSubscriberAttribution("user-1", Attribution{Network: "ads"})
+	}},
+	"GrantEntitlement": {"GrantEntitlement", func(c *Client) error {
+		_, err := c.GrantEntitlement("user-1", "pro", time.Hour)
+		return err
+	}},
+	"BulkDeleteSubscribers": {"BulkDeleteSubscribers", func(c *Client) error {
+		_, err := c.BulkDeleteSubscribers("app-1", []string{"user-1"})
+		return err
+	}},
+	"DeleteSubscribersWithPrefix": {"DeleteSubscribersWithPrefix", func(c *Client) error {
+		_, err := c.DeleteSubscribersWithPrefix("app-1", "test-")
+		return err
+	}},
+	"CreateProduct": {"CreateProduct", func(c *Client) error {
+		_, err := c.CreateProduct("app-1", "sku", "subscription", nil)
+		return err
+	}},
+	"UpdateProduct": {"UpdateProduct", func(c *Client) error {
+		_, err := c.UpdateProduct("app-1", "p1", ProductUpdate{EntitlementIDs: []string{"pro"}}, []string{"entitlement_ids"})
+		return err
+	}},
+	"DeleteProduct": {"DeleteProduct", func(c *Client) error { return c.DeleteProduct("app-1", "p1") }},
+	"BulkUpdateProductEntitlements": {"BulkUpdateProductEntitlements", func(c *Client) error {
+		_, err := c.BulkUpdateProductEntitlements("app-1", map[string][]string{"p1": {"pro"}})
+		return err
+	}},
+	"CreateEntitlement": {"CreateEntitlement", func(c *Client) error {
+		_, err := c.CreateEntitlement("app-1", "pro", nil)
+		return err
+	}},
+	"UpdateEntitlement": {"UpdateEntitlement", func(c *Client) error {
+		name := "pro"
+		_, err := c.UpdateEntitlement("app-1", "e1", EntitlementUpdate{Name: &name})
+		return err
+	}},
+	"SubmitReceipt": {"SubmitReceipt", func(c *Client) error {
+		_, err := c.SubmitReceipt("app-1", "user-1", "p1", AppleReceipt("data"))
+		return err
+	}},
+	"SubmitGoogleReceipt": {"SubmitReceipt", func(c *Client) error {
+		_, err := c.SubmitGoogleReceipt("app-1", "user-1", "com.example", "pro_monthly", "tok", "p1")
+		return err
+	}},
+	"SubmitAmazonReceipt": {"SubmitReceipt", func(c *Client) error {
+		_, err := c.SubmitAmazonReceipt("app-1", "user-1", "amzn-user", "rcpt", "p1")
+		return err
+	}},
+	"RecordPurchase": {"SubmitReceipt", func(c *Client) error {
+		_, err := c.RecordPurchase("app-1", "user-1", "sku", AppleReceipt("data"))
+		return err
+	}},
+	"SubmitReceipts": {"SubmitReceipts", func(c *Client) error {
+		_, err := c.SubmitReceipts([]ReceiptSubmission{{AppID: "app-1", AppUserID: "user-1", ProductID: "p1", Receipt: AppleReceipt("data")}})
+		return err
+	}},
+	"DeleteTransaction": {"DeleteTransaction", func(c *Client) error { return c.DeleteTransaction("tx-1") }},
+	"CreateWebhook": {"CreateWebhook", func(c *Client) error {
+		_, err := c.CreateWebhook("app-1", "https://hook.example.com")
+		return err
+	}},
+	"UpdateWebhook": {"UpdateWebhook", func(c *Client) error {
+		_, err := c.UpdateWebhook("wh-1", WebhookUpdate{SigningAlgorithm: SigningSHA512})
+		return err
+	}},
+	"DeleteWebhook": {"DeleteWebhook", func(c *Client) error { return c.DeleteWebhook("wh-1") }},
+	"EnableWebhook": {"EnableWebhook", func(c *Client) error {
+		_, err := c.EnableWebhook("wh-1")
+		return err
+	}},
+	"AckEvents":  {"AckEvents", func(c *Client) error { return c.AckEvents([]string{"ev1"}) }},
+	"AckThrough": {"AckThrough", func(c *Client) error { return c.AckThrough("2026-01-01T00:00:00+00:00") }},
+}
+
+// unauditedMethods are the Client methods that change nothing on the server.
+// ReceiptGrantsEntitlement and ValidateWebhookURL send POSTs but persist
+// nothing.
+var unauditedMethods = strings.Fields(`
+	BreakerState Close DecodedEvents EventsIterator ExportTransactions FindTransactionID
+	GetActiveEntitlements GetApp GetAppConfig GetAppFacets GetCurrentOffering
+	GetEntitlementDistribution GetEntitlementToken GetEvent GetMRRMovement
+	GetProduct GetProductRevenue GetProductWithEntitlements GetSubscriber
+	GetSubscriberLTV GetSubscribersByStore GetSubscriptionHistory GetTransaction
+	GetTransactionStatusHistory GetTrialConversion GetUserEntitlementsAcrossApps
+	GetWebhookHealth GetWebhookPublicKey GetWebhookSecret HasTransaction InFlight
+	InvalidateEntitlementToken InvalidateMetadata InvalidateOfferings
+	LatestEventCursor ListApps ListChargebacks ListEntitlementChanges
+	ListEntitlementSubscribers ListEntitlements ListEvents ListExpiringSubscribers
+	ListFailedWebhookDeliveries ListProductTransactions ListProducts ListRefunds
+	ListStoreTransactionIDs ListSubscribers ListTransactions ListWebhookDeliveries
+	ListWebhooks ReceiptGrantsEntitlement RefreshEntitlementToken Region
+	ServerInfo StreamSubscribers SubscriberEntitlementChangesSince
+	SubscriberExists ValidateWebhookURL VerifyWebhookSignature
+`)
+
+func TestEveryMutatingMethodIsAudited(t *testing.T) {
+	unaudited := map[string]bool{}
+	for _, name := range unauditedMethods {
+		unaudited[name] = true
+	}
+	ct := reflect.TypeOf(&Client{})
+	for i := 0; i < ct.NumMethod(); i++ {
+		name := strings.TrimSuffix(ct.Method(i).Name, "Context")
+		if _, ok := auditedCalls[name]; !ok && !unaudited[name] {
+			t.Errorf("%s is neither in auditedCalls nor in unauditedMethods", name)
+		}
+	}
+
+	var mutations int
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			mutations++
+		}
+		if r.Method == "GET" && r.URL.Path == "/v1/apps/app-1/products" {
+			w.Write([]byte(`[{"id":"p1","store_product_id":"sku"}]`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+	defer srv.Close()
+	for name, tc := range auditedCalls {
+		logger := &recordingAuditLogger{}
+		WithAuditLogger(logger)(c)
+		mutations = 0
+		if err := tc.call(c); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if mutations == 0 || len(logger.entries) != mutations {
+			t.Fatalf("%s: expected an entry for each of %d mutating requests, got %+v", name, mutations, logger.entries)
+		}
+		for _, entry := range logger.entries {
+			if entry.Operation != tc.operation {
+				t.Fatalf("%s: expected operation %s, got %+v", name, tc.operation, entry)
+			}
+		}
+	}
+}
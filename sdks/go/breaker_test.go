@@ -0,0 +1,113 @@
+package opencat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var healthy atomic.Bool
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if !healthy.Load() {
+			w.WriteHeader(502)
+			return
+		}
+		json.NewEncoder(w).Encode([]App{})
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithCircuitBreaker(2, 30*time.Millisecond))
+	for i := 0; i < 2; i++ {
+		if _, err := c.ListApps(); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	if c.BreakerState() != BreakerOpen {
+		t.Fatalf("expected open, got %s", c.BreakerState())
+	}
+	if _, err := c.ListApps(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected no request while open, got %d calls", calls)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if c.BreakerState() != BreakerHalfOpen {
+		t.Fatalf("expected half-open, got %s", c.BreakerState())
+	}
+	if _, err := c.ListApps(); err == nil {
+		t.Fatal("expected failed probe")
+	}
+	if c.BreakerState() != BreakerOpen {
+		t.Fatalf("expected open after failed probe, got %s", c.BreakerState())
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	healthy.Store(true)
+	if _, err := c.ListApps(); err != nil {
+		t.Fatal(err)
+	}
+	if c.BreakerState() != BreakerClosed {
+		t.Fatalf("expected closed, got %s", c.BreakerState())
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithCircuitBreaker(1, time.Minute))
+	c.GetSubscriber("missing")
+	c.GetSubscriber("missing")
+	if c.BreakerState() != BreakerClosed {
+		t.Fatalf("expected closed, got %s", c.BreakerState())
+	}
+}
+
+func TestCircuitBreakerProbeSurvivesThrottleWait(t *testing.T) {
+	var healthy atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", "60")
+			w.WriteHeader(502)
+			return
+		}
+		json.NewEncoder(w).Encode([]App{})
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithCircuitBreaker(1, 10*time.Millisecond), WithAdaptiveThrottle())
+	if _, err := c.ListApps(); err == nil {
+		t.Fatal("expected error")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if c.BreakerState() != BreakerHalfOpen {
+		t.Fatalf("expected half-open, got %s", c.BreakerState())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.ListAppsContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the throttle wait to hit the deadline, got %v", err)
+	}
+
+	healthy.Store(true)
+	if _, err := c.ListApps(); err != nil {
+		t.Fatalf("expected the next call to probe, got %v", err)
+	}
+	if c.BreakerState() != BreakerClosed {
+		t.Fatalf("expected closed, got %s", c.BreakerState())
+	}
+}
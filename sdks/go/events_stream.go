@@ -0,0 +1,192 @@
+package opencat
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// How long DecodedEvents waits before asking again once it has caught up.
+var eventPollInterval = 5 * time.Second
+
+// TypedEvent is an event with its payload decoded by Event.DecodePayload.
+type TypedEvent struct {
+	Event   Event
+	Payload any
+}
+
+// DecodedEvents streams the events created after since, decoded, oldest
+// first. since is the CreatedAt of an event as the server returned it, or ""
+// to start from the oldest event. It keeps polling for new events until ctx
+// is done, then closes both channels. At most bufferSize events are fetched
+// ahead of the consumer. A failed request or undecodable payload is sent on
+// the error channel and ends the stream; resume from the CreatedAt of the
+// last event received. Events sharing a CreatedAt are paged as described on
+// EventsIterator. WithOrder and WithCursor are ignored.
+func (c *Client) DecodedEvents(ctx context.Context, since string, bufferSize int, opts ...ListOption) (<-chan TypedEvent, <-chan error) {
+	events := make(chan TypedEvent, bufferSize)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(events)
+		defer close(errs)
+
+		pager := newEventPager(since)
+		opts := eventFeedOptions(opts)
+		for {
+			page, err := pager.fetch(ctx, c, opts)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+			for _, e := range page {
+				payload, err := e.DecodePayload()
+				if err != nil {
+					errs <- err
+					return
+				}
+				select {
+				case events <- TypedEvent{Event: e, Payload: payload}:
+				case <-ctx.Done():
+					return
+				}
+				pager.advance(e)
+			}
+			if len(page) > 0 {
+				continue
+			}
+			select {
+			case <-time.After(eventPollInterval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, errs
+}
+
+// EventIterator walks the event feed one event at a time, fetching pages as
+// needed. Create one with Client.EventsIterator.
+type EventIterator struct {
+	c     *Client
+	pager *eventPager
+	opts  []ListOption
+	page  []Event
+	done  bool
+}
+
+// eventsEpoch is a since cursor before every event. The server compares
+// since against each event's created_at as a string, and only returns events
+// oldest first when since is set.
+const eventsEpoch = "1970-01-01T00:00:00Z"
+
+// EventsIterator returns an iterator over the events created after since,
+// oldest first. since is the CreatedAt of an event as the server returned
+// it, or "" for the oldest event. Unlike DecodedEvents it does not wait for
+// new events: once the feed is exhausted, Next returns io.EOF. WithOrder and
+// WithCursor are ignored.
+//
+// The server pages by CreatedAt alone, so the iterator asks for each page
+// from the timestamp before the last event returned and skips the events it
+// has already returned by ID; events sharing a CreatedAt across a page
+// boundary are all returned. Only a run of same-timestamp events longer than
+// a page can lose events, as can resuming from Cursor, which does not record
+// which events at that timestamp were returned.
+func (c *Client) EventsIterator(since string, opts ...ListOption) *EventIterator {
+	return &EventIterator{c: c, pager: newEventPager(since), opts: eventFeedOptions(opts)}
+}
+
+// Next returns the next event, or io.EOF at the end of the feed. After an
+// error other than io.EOF, Next may be called again to retry the same page.
+func (it *EventIterator) Next(ctx context.Context) (*Event, error) {
+	for len(it.page) == 0 {
+		if it.done {
+			return nil, io.EOF
+		}
+		page, err := it.pager.fetch(ctx, it.c, it.opts)
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			it.done = true
+			continue
+		}
+		it.page = page
+	}
+	e := it.page[0]
+	it.page = it.page[1:]
+	it.pager.advance(e)
+	return &e, nil
+}
+
+// Cursor is the CreatedAt of the last event returned by Next, or the since
+// cursor the iterator started from. Save it to resume with a new iterator
+// later.
+func (it *EventIterator) Cursor() string {
+	return it.pager.last
+}
+
+// eventFeedOptions returns opts with WithOrder and WithCursor undone: the
+// feed is only walked oldest first, by since.
+func eventFeedOptions(opts []ListOption) []ListOption {
+	return append(append([]ListOption(nil), opts...), func(co *callOptions) {
+		co.query.Del("order")
+		co.query.Del("cursor")
+	})
+}
+
+// eventPager walks the event feed by CreatedAt without losing events that
+// share a timestamp across a page boundary.
+type eventPager struct {
+	// last is the CreatedAt of the last event returned, and seen the IDs of
+	// the events returned with that CreatedAt.
+	last string
+	seen map[string]bool
+	// since is the cursor sent to the server: the CreatedAt before last, so
+	// events tied with last are fetched again, or last itself once they are
+	// known to be exhausted.
+	since string
+}
+
+func newEventPager(since string) *eventPager {
+	return &eventPager{last: since, since: since, seen: map[string]bool{}}
+}
+
+// fetch returns the next page of events not yet returned, or none at the end
+// of the feed.
+func (p *eventPager) fetch(ctx context.Context, c *Client, opts []ListOption) ([]Event, error) {
+	for {
+		since := p.since
+		if since == "" {
+			since = eventsEpoch
+		}
+		page, err := c.ListEventsContext(ctx, since, opts...)
+		if err != nil {
+			return nil, err
+		}
+		fresh := make([]Event, 0, len(page))
+		for _, e := range page {
+			if e.CreatedAt <= since || e.CreatedAt < p.last || (e.CreatedAt == p.last && p.seen[e.ID]) {
+				continue
+			}
+			fresh = append(fresh, e)
+		}
+		if len(fresh) > 0 || len(page) == 0 || p.since == p.last {
+			return fresh, nil
+		}
+		// The page held only events already returned; ask from last itself.
+		p.since = p.last
+	}
+}
+
+// advance records that e was returned.
+func (p *eventPager) advance(e Event) {
+	if e.CreatedAt != p.last {
+		p.since, p.last = p.last, e.CreatedAt
+		p.seen = map[string]bool{}
+	}
+	p.seen[e.ID] = true
+}
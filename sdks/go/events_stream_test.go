@@ -0,0 +1,182 @@
+package opencat
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodedEvents(t *testing.T) {
+	defer func(d time.Duration) { eventPollInterval = d }(eventPollInterval)
+	eventPollInterval = time.Millisecond
+
+	all := []Event{
+		{ID: "ev1", EventType: "purchase", SchemaVersion: SchemaV2, Payload: `{"product_id":"p1"}`, CreatedAt: "2026-01-01T00:00:01+00:00"},
+		{ID: "ev2", EventType: "renewal", SchemaVersion: SchemaV2, Payload: `{"product_id":"p1"}`, CreatedAt: "2026-01-01T00:00:02+00:00"},
+		{ID: "ev3", EventType: "cancellation", Payload: `{}`, CreatedAt: "2026-01-01T00:00:03+00:00"},
+	}
+	c, srv := setupServer(t, eventFeed(all, 2))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, errs := c.DecodedEvents(ctx, "", 1)
+
+	var got []TypedEvent
+	for len(got) < len(all) {
+		select {
+		case e := <-events:
+			got = append(got, e)
+		case err := <-errs:
+			t.Fatal(err)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out after %d events", len(got))
+		}
+	}
+	if p, ok := got[1].Payload.(*PayloadV2); !ok || p.ProductID != "p1" {
+		t.Fatalf("unexpected payload %#v", got[1].Payload)
+	}
+	if got[0].Event.ID != "ev1" {
+		t.Fatalf("expected the oldest event first, got %+v", got[0])
+	}
+	if _, ok := got[2].Payload.(*PayloadV1); !ok || got[2].Event.ID != "ev3" {
+		t.Fatalf("unexpected event %+v", got[2])
+	}
+
+	cancel()
+	for range events {
+	}
+	if err, ok := <-errs; ok {
+		t.Fatalf("expected clean shutdown, got %v", err)
+	}
+}
+
+func TestDecodedEventsReportsErrors(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	})
+	defer srv.Close()
+
+	events, errs := c.DecodedEvents(context.Background(), "", 0)
+	if err := <-errs; err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, ok := <-events; ok {
+		t.Fatal("expected events channel to be closed")
+	}
+}
+
+// eventFeed serves all, sorted by CreatedAt, the way the server's
+// /v1/events does: with since, up to limit events created after it, oldest
+// first; without, the newest limit events, newest first.
+func eventFeed(all []Event, limit int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page := []Event{}
+		since := r.URL.Query().Get("since")
+		if since == "" {
+			for i := len(all) - 1; i >= 0 && len(page) < limit; i-- {
+				page = append(page, all[i])
+			}
+		} else {
+			for _, e := range all {
+				if e.CreatedAt > since && len(page) < limit {
+					page = append(page, e)
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(page)
+	}
+}
+
+func TestEventsIterator(t *testing.T) {
+	all := []Event{
+		{ID: "ev1", CreatedAt: "2026-01-01T00:00:01+00:00"},
+		{ID: "ev2", CreatedAt: "2026-01-01T00:00:02+00:00"},
+		{ID: "ev3", CreatedAt: "2026-01-01T00:00:03+00:00"},
+	}
+	c, srv := setupServer(t, eventFeed(all, 2))
+	defer srv.Close()
+
+	it := c.EventsIterator("")
+	var ids []string
+	for {
+		e, err := it.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, e.ID)
+	}
+	if strings.Join(ids, ",") != "ev1,ev2,ev3" {
+		t.Fatalf("unexpected events %v", ids)
+	}
+	if it.Cursor() != all[2].CreatedAt {
+		t.Fatalf("unexpected cursor %q", it.Cursor())
+	}
+	if _, err := it.Next(context.Background()); err != io.EOF {
+		t.Fatalf("expected io.EOF to persist, got %v", err)
+	}
+
+	it = c.EventsIterator(all[0].CreatedAt)
+	if e, err := it.Next(context.Background()); err != nil || e.ID != "ev2" {
+		t.Fatalf("expected to resume at ev2, got %+v, %v", e, err)
+	}
+}
+
+func TestEventsIteratorKeepsTiedEventsAcrossPages(t *testing.T) {
+	all := []Event{
+		{ID: "ev1", CreatedAt: "2026-01-01T00:00:01+00:00"},
+		{ID: "ev2", CreatedAt: "2026-01-01T00:00:02+00:00"},
+		{ID: "ev3", CreatedAt: "2026-01-01T00:00:02+00:00"},
+		{ID: "ev4", CreatedAt: "2026-01-01T00:00:03+00:00"},
+	}
+	feed := eventFeed(all, 2)
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query(); q.Has("order") || q.Has("cursor") {
+			t.Errorf("expected order and cursor to be dropped, got %s", r.URL.RawQuery)
+		}
+		feed(w, r)
+	})
+	defer srv.Close()
+
+	it := c.EventsIterator("", WithOrder(OrderDesc), WithCursor("ev9"))
+	var ids []string
+	for {
+		e, err := it.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, e.ID)
+	}
+	if strings.Join(ids, ",") != "ev1,ev2,ev3,ev4" {
+		t.Fatalf("expected every event once, got %v", ids)
+	}
+
+	defer func(d time.Duration) { eventPollInterval = d }(eventPollInterval)
+	eventPollInterval = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, errs := c.DecodedEvents(ctx, "", 0, WithOrder(OrderDesc))
+	ids = nil
+	for len(ids) < len(all) {
+		select {
+		case e := <-events:
+			ids = append(ids, e.Event.ID)
+		case err := <-errs:
+			t.Fatal(err)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out after %v", ids)
+		}
+	}
+	if strings.Join(ids, ",") != "ev1,ev2,ev3,ev4" {
+		t.Fatalf("expected every event once, got %v", ids)
+	}
+}
@@ -0,0 +1,110 @@
+// Package fixtures seeds an OpenCat server with a coherent set of linked
+// resources for integration tests and demos.
+package fixtures
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+
+	opencat "github.com/opencat/opencat-go"
+)
+
+type Fixtures struct {
+	AppID          string
+	EntitlementIDs []string
+	ProductIDs     []string
+	AppUserIDs     []string
+	TransactionIDs []string
+}
+
+var entitlementNames = []string{"basic", "pro"}
+
+var productTypes = []string{"subscription", "subscription", "non_consumable"}
+
+// Generate creates an app with entitlements, products and subscribers with
+// transactions. Names and purchases are derived from seed, and resources that
+// already exist are reused, so running it twice with the same seed yields the
+// same set.
+func Generate(c *opencat.Client, seed int64) (*Fixtures, error) {
+	return GenerateContext(context.Background(), c, seed)
+}
+
+func GenerateContext(ctx context.Context, c *opencat.Client, seed int64) (*Fixtures, error) {
+	rng := rand.New(rand.NewSource(seed))
+	f := &Fixtures{}
+
+	app, err := ensureApp(ctx, c, seed)
+	if err != nil {
+		return nil, err
+	}
+	f.AppID = app.ID
+
+	for _, name := range entitlementNames {
+		e, err := c.CreateEntitlementContext(ctx, app.ID, name, nil, opencat.WithUpsert())
+		if err != nil {
+			return nil, err
+		}
+		f.EntitlementIDs = append(f.EntitlementIDs, e.ID)
+	}
+
+	for i, productType := range productTypes {
+		storeProductID := fmt.Sprintf("com.fixture%d.product%d", seed, i)
+		entitlementIDs := f.EntitlementIDs[:1+rng.Intn(len(f.EntitlementIDs))]
+		p, err := c.CreateProductContext(ctx, app.ID, storeProductID, productType, entitlementIDs, opencat.WithUpsert())
+		if err != nil {
+			return nil, err
+		}
+		f.ProductIDs = append(f.ProductIDs, p.ID)
+	}
+
+	subscribers := 3 + rng.Intn(5)
+	for i := 0; i < subscribers; i++ {
+		appUserID := fmt.Sprintf("fixture-%d-user-%d", seed, i)
+		productID := f.ProductIDs[rng.Intn(len(f.ProductIDs))]
+		txIDs, err := ensurePurchase(ctx, c, app.ID, appUserID, productID, rng.Int63())
+		if err != nil {
+			return nil, err
+		}
+		f.AppUserIDs = append(f.AppUserIDs, appUserID)
+		f.TransactionIDs = append(f.TransactionIDs, txIDs...)
+	}
+	return f, nil
+}
+
+func ensureApp(ctx context.Context, c *opencat.Client, seed int64) (*opencat.App, error) {
+	bundleID := fmt.Sprintf("com.opencat.fixture%d", seed)
+	apps, err := c.ListAppsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range apps {
+		if apps[i].BundleID == bundleID {
+			return &apps[i], nil
+		}
+	}
+	return c.CreateAppContext(ctx, fmt.Sprintf("Fixture %d", seed), "ios", bundleID)
+}
+
+func ensurePurchase(ctx context.Context, c *opencat.Client, appID, appUserID, productID string, receiptSeed int64) ([]string, error) {
+	info, err := c.GetSubscriberContext(ctx, appUserID)
+	var apiErr *opencat.Error
+	switch {
+	case err == nil && len(info.Transactions) > 0:
+		ids := make([]string, len(info.Transactions))
+		for i, tx := range info.Transactions {
+			ids[i] = tx.ID
+		}
+		return ids, nil
+	case err != nil && !(errors.As(err, &apiErr) && apiErr.StatusCode == 404):
+		return nil, err
+	}
+
+	receipt := fmt.Sprintf("fixture-receipt-%x", receiptSeed)
+	tx, err := c.SubmitReceiptContext(ctx, appID, appUserID, productID, opencat.AppleReceipt(receipt))
+	if err != nil {
+		return nil, err
+	}
+	return []string{tx.ID}, nil
+}
@@ -0,0 +1,103 @@
+package fixtures
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	opencat "github.com/opencat/opencat-go"
+)
+
+// fakeServer is an in-memory stand-in for the endpoints Generate uses.
+type fakeServer struct {
+	mu           sync.Mutex
+	next         int
+	apps         []opencat.App
+	entitlements []opencat.Entitlement
+	products     []opencat.Product
+	subscribers  map[string]*opencat.SubscriberInfo
+}
+
+func (s *fakeServer) id(prefix string) string {
+	s.next++
+	return fmt.Sprintf("%s%d", prefix, s.next)
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var body map[string]any
+	json.NewDecoder(r.Body).Decode(&body)
+	str := func(k string) string { v, _ := body[k].(string); return v }
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case r.URL.Path == "/v1/apps" && r.Method == "GET":
+		json.NewEncoder(w).Encode(s.apps)
+	case r.URL.Path == "/v1/apps":
+		app := opencat.App{ID: s.id("app"), Name: str("name"), BundleID: str("bundle_id")}
+		s.apps = append(s.apps, app)
+		json.NewEncoder(w).Encode(app)
+	case len(parts) == 4 && parts[3] == "entitlements" && r.Method == "GET":
+		json.NewEncoder(w).Encode(s.entitlements)
+	case len(parts) == 4 && parts[3] == "entitlements":
+		e := opencat.Entitlement{ID: s.id("ent"), AppID: parts[2], Name: str("name")}
+		s.entitlements = append(s.entitlements, e)
+		json.NewEncoder(w).Encode(e)
+	case len(parts) == 4 && parts[3] == "products" && r.Method == "GET":
+		json.NewEncoder(w).Encode(s.products)
+	case len(parts) == 4 && parts[3] == "products":
+		p := opencat.Product{ID: s.id("prod"), AppID: parts[2], StoreProductID: str("store_product_id")}
+		s.products = append(s.products, p)
+		json.NewEncoder(w).Encode(p)
+	case parts[1] == "subscribers":
+		info, ok := s.subscribers[parts[2]]
+		if !ok {
+			http.Error(w, "Subscriber not found", 404)
+			return
+		}
+		json.NewEncoder(w).Encode(info)
+	case parts[1] == "receipts":
+		info, ok := s.subscribers[str("app_user_id")]
+		if !ok {
+			info = &opencat.SubscriberInfo{Subscriber: opencat.Subscriber{ID: s.id("sub"), AppUserID: str("app_user_id")}}
+			s.subscribers[str("app_user_id")] = info
+		}
+		tx := opencat.Transaction{ID: s.id("tx"), SubscriberID: info.Subscriber.ID, ProductID: str("product_id")}
+		info.Transactions = append(info.Transactions, tx)
+		json.NewEncoder(w).Encode(tx)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestGenerateIsIdempotent(t *testing.T) {
+	srv := httptest.NewServer(&fakeServer{subscribers: map[string]*opencat.SubscriberInfo{}})
+	defer srv.Close()
+	c := opencat.NewClient(srv.URL, "test-key")
+
+	first, err := Generate(c, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.AppID == "" || len(first.EntitlementIDs) != 2 || len(first.ProductIDs) != 3 || len(first.AppUserIDs) < 3 {
+		t.Fatalf("unexpected fixtures %+v", first)
+	}
+	if len(first.TransactionIDs) != len(first.AppUserIDs) {
+		t.Fatalf("expected one transaction per subscriber, got %+v", first)
+	}
+
+	second, err := Generate(c, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("expected identical fixtures:\n%+v\n%+v", first, second)
+	}
+}
@@ -0,0 +1,173 @@
+package opencat
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Apps whose metadata a client caches at once; the least recently used app
+// is evicted beyond this.
+const metadataCacheApps = 256
+
+type metadataCache struct {
+	ttl time.Duration
+	max int
+
+	mu   sync.Mutex
+	lru  *list.List
+	apps map[string]*list.Element
+}
+
+type appMetadata struct {
+	appID          string
+	products       []Product
+	productsAt     time.Time
+	entitlements   []Entitlement
+	entitlementsAt time.Time
+	product        map[string]cachedProduct
+}
+
+type cachedProduct struct {
+	product   Product
+	fetchedAt time.Time
+}
+
+func newMetadataCache(ttl time.Duration) *metadataCache {
+	return &metadataCache{ttl: ttl, max: metadataCacheApps, lru: list.New(), apps: map[string]*list.Element{}}
+}
+
+// app returns the entry for appID, marking it most recently used. The
+// caller must hold mu.
+func (mc *metadataCache) app(appID string) *appMetadata {
+	if el, ok := mc.apps[appID]; ok {
+		mc.lru.MoveToFront(el)
+		return el.Value.(*appMetadata)
+	}
+	md := &appMetadata{appID: appID, product: map[string]cachedProduct{}}
+	mc.apps[appID] = mc.lru.PushFront(md)
+	for mc.lru.Len() > mc.max {
+		oldest := mc.lru.Back()
+		mc.lru.Remove(oldest)
+		delete(mc.apps, oldest.Value.(*appMetadata).appID)
+	}
+	return md
+}
+
+func (mc *metadataCache) fresh(at time.Time) bool {
+	return !at.IsZero() && time.Since(at) < mc.ttl
+}
+
+// cachedMetadata runs fetch through the cache when it is enabled and the call
+// has no options, since options can change what the server returns.
+func cachedMetadata[T any](c *Client, appID string, opts []CallOption, get func(*appMetadata) (T, bool), put func(*appMetadata, T), fetch func() (T, error)) (T, error) {
+	mc := c.metadata
+	if mc == nil || len(opts) > 0 {
+		return fetch()
+	}
+	mc.mu.Lock()
+	if v, ok := get(mc.app(appID)); ok {
+		mc.mu.Unlock()
+		return v, nil
+	}
+	mc.mu.Unlock()
+
+	v, err := fetch()
+	if err != nil {
+		return v, err
+	}
+	mc.mu.Lock()
+	put(mc.app(appID), v)
+	mc.mu.Unlock()
+	return v, nil
+}
+
+// InvalidateMetadata drops the cached products and entitlements of the given
+// apps, or of every app if none are given. Products and entitlements created
+// or changed through this client invalidate their app automatically.
+func (c *Client) InvalidateMetadata(appIDs ...string) {
+	mc := c.metadata
+	if mc == nil {
+		return
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if len(appIDs) == 0 {
+		mc.lru.Init()
+		mc.apps = map[string]*list.Element{}
+		return
+	}
+	for _, appID := range appIDs {
+		if el, ok := mc.apps[appID]; ok {
+			mc.lru.Remove(el)
+			delete(mc.apps, appID)
+		}
+	}
+}
+
+// GetProduct fetches a single product of an app. An unknown productID is an
+// error for which IsNotFound reports true.
+func (c *Client) GetProduct(appID, productID string, opts ...CallOption) (*Product, error) {
+	return c.GetProductContext(context.Background(), appID, productID, opts...)
+}
+
+func (c *Client) GetProductContext(ctx context.Context, appID, productID string, opts ...CallOption) (*Product, error) {
+	product, err := cachedMetadata(c, appID, opts,
+		func(md *appMetadata) (Product, bool) {
+			p, ok := md.product[productID]
+			return p.product, ok && c.metadata.fresh(p.fetchedAt)
+		},
+		func(md *appMetadata, p Product) {
+			md.product[productID] = cachedProduct{product: p, fetchedAt: time.Now()}
+		},
+		func() (Product, error) {
+			var result Product
+			err := c.request(ctx, "GET", fmt.Sprintf("/v1/apps/%s/products/%s", appID, productID), nil, nil, &result, opts)
+			return result, err
+		})
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+func (c *Client) listProductsCached(ctx context.Context, appID string, opts []CallOption) ([]Product, error) {
+	products, err := cachedMetadata(c, appID, opts,
+		func(md *appMetadata) ([]Product, bool) {
+			return md.products, c.metadata.fresh(md.productsAt)
+		},
+		func(md *appMetadata, products []Product) {
+			md.products, md.productsAt = products, time.Now()
+		},
+		func() ([]Product, error) {
+			var result []Product
+			err := c.request(ctx, "GET", fmt.Sprintf("/v1/apps/%s/products", appID), nil, nil, &result, opts)
+			return result, err
+		})
+	if c.metadata != nil && products != nil {
+		// Callers may modify the slice; never hand out the cached one.
+		products = append([]Product(nil), products...)
+	}
+	return products, err
+}
+
+func (c *Client) listEntitlementsCached(ctx context.Context, appID string, opts []CallOption) ([]Entitlement, error) {
+	entitlements, err := cachedMetadata(c, appID, opts,
+		func(md *appMetadata) ([]Entitlement, bool) {
+			return md.entitlements, c.metadata.fresh(md.entitlementsAt)
+		},
+		func(md *appMetadata, entitlements []Entitlement) {
+			md.entitlements, md.entitlementsAt = entitlements, time.Now()
+		},
+		func() ([]Entitlement, error) {
+			var result []Entitlement
+			err := c.request(ctx, "GET", fmt.Sprintf("/v1/apps/%s/entitlements", appID), nil, nil, &result, opts)
+			return result, err
+		})
+	if c.metadata != nil && entitlements != nil {
+		entitlements = append([]Entitlement(nil), entitlements...)
+	}
+	return entitlements, err
+}
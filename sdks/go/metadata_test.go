@@ -0,0 +1,122 @@
+package opencat
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMetadataCache(t *testing.T) {
+	var calls sync.Map
+	count := func(path string) int32 {
+		n, _ := calls.LoadOrStore(path, new(int32))
+		return atomic.LoadInt32(n.(*int32))
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := calls.LoadOrStore(r.Method+" "+r.URL.Path, new(int32))
+		atomic.AddInt32(n.(*int32), 1)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v1/apps/app-1/products":
+			fmt.Fprint(w, `[{"id":"p1","app_id":"app-1","store_product_id":"pro"}]`)
+		case "GET /v1/apps/app-1/products/p1":
+			fmt.Fprint(w, `{"id":"p1","app_id":"app-1","store_product_id":"pro"}`)
+		case "GET /v1/apps/app-1/entitlements", "GET /v1/apps/app-2/entitlements":
+			fmt.Fprint(w, `[{"id":"e1","name":"premium"}]`)
+		case "POST /v1/apps/app-1/entitlements":
+			fmt.Fprint(w, `{"id":"e2","name":"gold"}`)
+		default:
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithMetadataCache(time.Minute))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.ListProducts("app-1"); err != nil {
+				t.Error(err)
+			}
+			if _, err := c.GetProduct("app-1", "p1"); err != nil {
+				t.Error(err)
+			}
+			if _, err := c.ListEntitlements("app-1"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	products, _ := c.ListProducts("app-1")
+	products[0].StoreProductID = "mutated"
+	if again, _ := c.ListProducts("app-1"); again[0].StoreProductID != "pro" {
+		t.Fatalf("cached products were modified through a returned slice")
+	}
+	before := count("GET /v1/apps/app-1/products")
+	if _, err := c.ListProducts("app-1", WithFields("id")); err != nil {
+		t.Fatal(err)
+	}
+	if count("GET /v1/apps/app-1/products") != before+1 {
+		t.Fatal("expected a call with options to bypass the cache")
+	}
+
+	if _, err := c.ListEntitlements("app-2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateEntitlement("app-1", "gold", nil); err != nil {
+		t.Fatal(err)
+	}
+	c.ListEntitlements("app-1")
+	c.ListEntitlements("app-2")
+	if n := count("GET /v1/apps/app-1/entitlements"); n < 2 {
+		t.Fatalf("expected creating an entitlement to invalidate app-1, got %d fetches", n)
+	}
+	if n := count("GET /v1/apps/app-2/entitlements"); n != 1 {
+		t.Fatalf("expected app-2 to stay cached, got %d fetches", n)
+	}
+
+	c.InvalidateMetadata()
+	c.ListEntitlements("app-2")
+	if n := count("GET /v1/apps/app-2/entitlements"); n != 2 {
+		t.Fatalf("expected a refetch after invalidating everything, got %d fetches", n)
+	}
+}
+
+func TestMetadataCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	mc := newMetadataCache(time.Minute)
+	mc.max = 2
+	mc.app("a").entitlementsAt = time.Now()
+	mc.app("b")
+	mc.app("a")
+	mc.app("c")
+	if _, ok := mc.apps["b"]; ok {
+		t.Fatal("expected b to be evicted")
+	}
+	if _, ok := mc.apps["a"]; !ok || !mc.fresh(mc.app("a").entitlementsAt) {
+		t.Fatal("expected a to stay cached")
+	}
+}
+
+func TestMetadataCacheExpires(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		fmt.Fprint(w, `[]`)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithMetadataCache(10*time.Millisecond))
+	c.ListProducts("app-1")
+	c.ListProducts("app-1")
+	time.Sleep(15 * time.Millisecond)
+	c.ListProducts("app-1")
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("expected 2 fetches, got %d", n)
+	}
+}
@@ -1,5 +1,83 @@
 package opencat
 
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Response describes the HTTP response to a call made with WithResponse.
+type Response struct {
+	// Raw is the undecoded body, set only when the client was created with
+	// WithCaptureRaw.
+	Raw json.RawMessage
+
+	// Created reports whether a create call made with WithUpsert created a
+	// new resource rather than returning an existing one.
+	Created bool
+
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// RequestID is the server's X-Request-Id for the call; quote it when
+	// reporting a problem.
+	RequestID string
+	// RateLimitRemaining is how many requests the API key may still make
+	// before RateLimitReset. RateLimitReset is zero if the server sent no
+	// rate-limit headers.
+	RateLimitRemaining int
+	RateLimitReset     time.Time
+}
+
+type Store string
+
+const (
+	StoreApple  Store = "apple"
+	StoreGoogle Store = "google"
+	StoreAmazon Store = "amazon"
+	StoreStripe Store = "stripe"
+)
+
+func (s Store) Valid() bool {
+	switch s {
+	case StoreApple, StoreGoogle, StoreAmazon, StoreStripe:
+		return true
+	}
+	return false
+}
+
+type ProductType string
+
+const (
+	ProductSubscription  ProductType = "subscription"
+	ProductConsumable    ProductType = "consumable"
+	ProductNonConsumable ProductType = "non_consumable"
+)
+
+func (t ProductType) Valid() bool {
+	switch t {
+	case ProductSubscription, ProductConsumable, ProductNonConsumable:
+		return true
+	}
+	return false
+}
+
+type ServerInfo struct {
+	Status     string `json:"status"`
+	Version    string `json:"version"`
+	APIVersion string `json:"api_version,omitempty"`
+}
+
+// Compatible reports whether the server speaks the same major API version as
+// this SDK. Servers that do not report an API version are assumed compatible.
+func (s *ServerInfo) Compatible() bool {
+	if s.APIVersion == "" {
+		return true
+	}
+	major, _, _ := strings.Cut(s.APIVersion, ".")
+	want, _, _ := strings.Cut(APIVersion, ".")
+	return major == want
+}
+
 type App struct {
 	ID                        string  `json:"id"`
 	Name                      string  `json:"name"`
@@ -10,27 +88,182 @@ type App struct {
 	UpdatedAt                 string  `json:"updated_at"`
 }
 
+type AppConfig struct {
+	App App `json:"app"`
+	// Credentials reports, per store, whether credentials are configured.
+	Credentials      map[Store]bool    `json:"credentials"`
+	Webhooks         []WebhookEndpoint `json:"webhooks"`
+	EntitlementCount int               `json:"entitlement_count"`
+	ProductCount     int               `json:"product_count"`
+}
+
+// AppFacets lists the distinct values in use across an app's products,
+// transactions, and entitlements.
+type AppFacets struct {
+	Stores           []Store       `json:"stores"`
+	ProductTypes     []ProductType `json:"product_types"`
+	EntitlementNames []string      `json:"entitlement_names"`
+}
+
 type Subscriber struct {
-	ID        string `json:"id"`
-	AppID     string `json:"app_id"`
-	AppUserID string `json:"app_user_id"`
-	CreatedAt string `json:"created_at"`
+	ID          string       `json:"id"`
+	AppID       string       `json:"app_id"`
+	AppUserID   string       `json:"app_user_id"`
+	Email       *string      `json:"email,omitempty"`
+	DisplayName *string      `json:"display_name,omitempty"`
+	Attribution *Attribution `json:"attribution,omitempty"`
+	CreatedAt   string       `json:"created_at"`
+}
+
+// AnonymousIDPrefix starts the app user IDs the server generates for
+// anonymous subscribers.
+const AnonymousIDPrefix = "$anonymous:"
+
+// IsAnonymous reports whether s was created with CreateAnonymousSubscriber.
+func (s *Subscriber) IsAnonymous() bool {
+	return strings.HasPrefix(s.AppUserID, AnonymousIDPrefix)
+}
+
+// SubscriberProfile updates the profile fields of a subscriber. Nil fields
+// are left unchanged.
+type SubscriberProfile struct {
+	Email       *string `json:"email,omitempty"`
+	DisplayName *string `json:"display_name,omitempty"`
+}
+
+// Attribution records where a subscriber was acquired. AdvertisingIDHash is
+// a hash of the IDFA or GAID, never the raw identifier.
+type Attribution struct {
+	Network           string `json:"network,omitempty"`
+	Campaign          string `json:"campaign,omitempty"`
+	AdGroup           string `json:"ad_group,omitempty"`
+	Keyword           string `json:"keyword,omitempty"`
+	AdvertisingIDHash string `json:"advertising_id_hash,omitempty"`
 }
 
 type EntitlementInfo struct {
 	ID             string  `json:"id"`
 	IsActive       bool    `json:"is_active"`
 	ProductID      string  `json:"product_id"`
-	Store          string  `json:"store"`
+	Store          Store   `json:"store"`
 	ExpirationDate *string `json:"expiration_date,omitempty"`
 	WillRenew      bool    `json:"will_renew"`
 	PurchaseDate   *string `json:"purchase_date,omitempty"`
 }
 
+type EntitlementGrant struct {
+	Entitlement EntitlementInfo `json:"entitlement"`
+	// Stacked is set when the grant extends an entitlement the subscriber
+	// already had.
+	Stacked bool `json:"stacked"`
+	// Preview is set when the grant was computed with DryRun and not saved.
+	Preview bool `json:"preview"`
+}
+
 type SubscriberInfo struct {
 	Subscriber         Subscriber        `json:"subscriber"`
-	ActiveEntitlements []EntitlementInfo  `json:"active_entitlements"`
+	ActiveEntitlements []EntitlementInfo `json:"active_entitlements"`
 	Transactions       []Transaction     `json:"transactions"`
+
+	// Stale is set when GetSubscriber could not reach the server and returned
+	// the last state it fetched instead; see WithStaleOnError.
+	Stale bool `json:"-"`
+}
+
+// LTV is the revenue a subscriber has generated, net of refunds. Amounts are
+// in micros; TotalMicros is converted to Currency, ByCurrency is as charged.
+type LTV struct {
+	TotalMicros       int64            `json:"total_micros"`
+	Currency          string           `json:"currency"`
+	ByCurrency        map[string]int64 `json:"by_currency"`
+	TransactionCount  int              `json:"transaction_count"`
+	FirstPurchaseDate *string          `json:"first_purchase_date,omitempty"`
+	LastPurchaseDate  *string          `json:"last_purchase_date,omitempty"`
+}
+
+// MRRMovement breaks the change in MRR over a period into its components.
+// Amounts are in micros of Currency; ChurnMicros and ContractionMicros are
+// reported as positive values.
+type MRRMovement struct {
+	Currency           string `json:"currency"`
+	NewMicros          int64  `json:"new_micros"`
+	ExpansionMicros    int64  `json:"expansion_micros"`
+	ContractionMicros  int64  `json:"contraction_micros"`
+	ReactivationMicros int64  `json:"reactivation_micros"`
+	ChurnMicros        int64  `json:"churn_micros"`
+	NetMicros          int64  `json:"net_micros"`
+}
+
+type Granularity string
+
+const (
+	GranularityDay   Granularity = "day"
+	GranularityWeek  Granularity = "week"
+	GranularityMonth Granularity = "month"
+)
+
+// RevenuePoint is the revenue of one bucket starting at Start. Amounts are in
+// micros of Currency, like MRRMovement; NetMicros is GrossMicros less refunds.
+type RevenuePoint struct {
+	Start       string `json:"start"`
+	Currency    string `json:"currency"`
+	GrossMicros int64  `json:"gross_micros"`
+	NetMicros   int64  `json:"net_micros"`
+}
+
+// ConversionStat counts trials of a product. Trials still running at the end
+// of the period are counted as started but not converted.
+type ConversionStat struct {
+	ProductID     string  `json:"product_id"`
+	TrialsStarted int     `json:"trials_started"`
+	Converted     int     `json:"converted"`
+	Rate          float64 `json:"rate"`
+}
+
+type SubscriptionEventType string
+
+const (
+	SubscriptionPurchased   SubscriptionEventType = "purchase"
+	SubscriptionRenewed     SubscriptionEventType = "renewal"
+	SubscriptionCancelled   SubscriptionEventType = "cancellation"
+	SubscriptionReactivated SubscriptionEventType = "reactivation"
+	SubscriptionExpired     SubscriptionEventType = "expiration"
+)
+
+// SubscriptionHistory is the life of one subscription, oldest entry first.
+// The first entry is the original purchase.
+type SubscriptionHistory struct {
+	AppUserID    string                     `json:"app_user_id"`
+	ProductID    string                     `json:"product_id"`
+	RenewalCount int                        `json:"renewal_count"`
+	Timeline     []SubscriptionHistoryEntry `json:"timeline"`
+}
+
+type SubscriptionHistoryEntry struct {
+	Type          SubscriptionEventType `json:"type"`
+	OccurredAt    string                `json:"occurred_at"`
+	TransactionID *string               `json:"transaction_id,omitempty"`
+	PriceMicros   *int64                `json:"price_micros,omitempty"`
+	Currency      *string               `json:"currency,omitempty"`
+}
+
+// Renewals returns the renewal entries of the timeline in order.
+func (h *SubscriptionHistory) Renewals() []SubscriptionHistoryEntry {
+	var renewals []SubscriptionHistoryEntry
+	for _, e := range h.Timeline {
+		if e.Type == SubscriptionRenewed {
+			renewals = append(renewals, e)
+		}
+	}
+	return renewals
+}
+
+// EntitlementToken is a JWT, signed by the server, listing a subscriber's
+// active entitlements.
+type EntitlementToken struct {
+	Token     string    `json:"token"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 type Entitlement struct {
@@ -41,41 +274,349 @@ type Entitlement struct {
 	CreatedAt   string  `json:"created_at"`
 }
 
+// EntitlementUpdate renames or redescribes an entitlement. Nil fields are
+// left unchanged.
+type EntitlementUpdate struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+type EntitlementChangeType string
+
+const (
+	EntitlementGranted EntitlementChangeType = "granted"
+	EntitlementRevoked EntitlementChangeType = "revoked"
+	EntitlementExpired EntitlementChangeType = "expired"
+)
+
+type EntitlementChange struct {
+	AppUserID     string                `json:"app_user_id"`
+	EntitlementID string                `json:"entitlement_id"`
+	Type          EntitlementChangeType `json:"type"`
+	ProductID     *string               `json:"product_id,omitempty"`
+	OccurredAt    string                `json:"occurred_at"`
+}
+
 type Product struct {
-	ID             string `json:"id"`
-	AppID          string `json:"app_id"`
-	StoreProductID string `json:"store_product_id"`
-	ProductType    string `json:"product_type"`
-	CreatedAt      string `json:"created_at"`
+	ID             string      `json:"id"`
+	AppID          string      `json:"app_id"`
+	StoreProductID string      `json:"store_product_id"`
+	ProductType    ProductType `json:"product_type"`
+	EntitlementIDs []string    `json:"entitlement_ids"`
+	// ActiveSubscriberCount is only populated by ListProducts called with
+	// WithSubscriberCounts.
+	ActiveSubscriberCount *int   `json:"active_subscriber_count,omitempty"`
+	CreatedAt             string `json:"created_at"`
+}
+
+// ProductUpdate holds new values for UpdateProduct. Only the fields named in
+// the update mask are sent.
+type ProductUpdate struct {
+	ProductType    ProductType `json:"product_type,omitempty"`
+	EntitlementIDs []string    `json:"entitlement_ids,omitempty"`
+	DisplayName    *string     `json:"display_name,omitempty"`
+	Description    *string     `json:"description,omitempty"`
+}
+
+type Offering struct {
+	Products []OfferingProduct `json:"offerings"`
+}
+
+type OfferingProduct struct {
+	StoreProductID     string      `json:"store_product_id"`
+	ProductType        ProductType `json:"product_type"`
+	DisplayName        string      `json:"display_name"`
+	Description        *string     `json:"description,omitempty"`
+	PriceMicros        int64       `json:"price_micros"`
+	Currency           string      `json:"currency"`
+	SubscriptionPeriod *string     `json:"subscription_period,omitempty"`
+	TrialPeriod        *string     `json:"trial_period,omitempty"`
+	Entitlements       []string    `json:"entitlements"`
 }
 
 type Transaction struct {
 	ID                 string  `json:"id"`
 	SubscriberID       string  `json:"subscriber_id"`
 	ProductID          string  `json:"product_id"`
-	Store              string  `json:"store"`
+	Store              Store   `json:"store"`
 	StoreTransactionID string  `json:"store_transaction_id"`
 	PurchaseDate       string  `json:"purchase_date"`
 	ExpirationDate     *string `json:"expiration_date,omitempty"`
 	Status             string  `json:"status"`
-	RawReceipt         *string `json:"raw_receipt,omitempty"`
-	CreatedAt          string  `json:"created_at"`
-	UpdatedAt          string  `json:"updated_at"`
+	// StatusReason explains how the transaction reached Status, when the
+	// store reported it.
+	StatusReason *StatusReason `json:"status_reason,omitempty"`
+	RawReceipt   *string       `json:"raw_receipt,omitempty"`
+	CreatedAt    string        `json:"created_at"`
+	UpdatedAt    string        `json:"updated_at"`
+
+	// Set by the store while a renewal payment is failing.
+	GracePeriodStart *time.Time `json:"grace_period_start,omitempty"`
+	GracePeriodEnd   *time.Time `json:"grace_period_end,omitempty"`
+	NextRetryAt      *time.Time `json:"next_retry_at,omitempty"`
+}
+
+type StatusReason string
+
+const (
+	ReasonVoluntaryCancellation StatusReason = "voluntary_cancellation"
+	ReasonBillingFailure        StatusReason = "billing_failure"
+	ReasonRefund                StatusReason = "refund"
+)
+
+// Involuntary reports whether the reason is a payment problem rather than
+// a decision by the subscriber.
+func (r StatusReason) Involuntary() bool {
+	return r == ReasonBillingFailure
+}
+
+type StatusChange struct {
+	From      string        `json:"from"`
+	To        string        `json:"to"`
+	Reason    *StatusReason `json:"reason,omitempty"`
+	ChangedAt string        `json:"changed_at"`
+}
+
+// GracePeriodRemaining is how long the subscriber keeps access while the store
+// retries a failed renewal, or 0 outside a grace period.
+func (t *Transaction) GracePeriodRemaining() time.Duration {
+	return remaining(t.GracePeriodEnd)
+}
+
+// UntilNextRetry is how long until the store next attempts to charge for a
+// failed renewal, or 0 if no retry is scheduled.
+func (t *Transaction) UntilNextRetry() time.Duration {
+	return remaining(t.NextRetryAt)
+}
+
+func remaining(until *time.Time) time.Duration {
+	if until == nil {
+		return 0
+	}
+	if d := time.Until(*until); d > 0 {
+		return d
+	}
+	return 0
 }
 
 type WebhookEndpoint struct {
-	ID        string `json:"id"`
-	AppID     string `json:"app_id"`
-	URL       string `json:"url"`
-	Secret    string `json:"secret"`
-	Active    bool   `json:"active"`
-	CreatedAt string `json:"created_at"`
+	ID                   string           `json:"id"`
+	AppID                string           `json:"app_id"`
+	URL                  string           `json:"url"`
+	Secret               Secret           `json:"secret"`
+	Active               bool             `json:"active"`
+	AutoDisableThreshold *int             `json:"auto_disable_threshold,omitempty"`
+	ConsecutiveFailures  int              `json:"consecutive_failures"`
+	DisabledReason       *string          `json:"disabled_reason,omitempty"`
+	SigningAlgorithm     SigningAlgorithm `json:"signing_algorithm,omitempty"`
+	// CustomHeaders are sent with every delivery. Their values are
+	// write-only: the server returns them redacted.
+	CustomHeaders map[string]Secret `json:"custom_headers,omitempty"`
+	CreatedAt     string            `json:"created_at"`
+}
+
+// MarshalRevealedJSON encodes e as json.Marshal does, but with Secret and
+// the CustomHeaders values in plain text. Use it to store an endpoint where
+// its secret must survive, such as a secrets manager.
+func (e WebhookEndpoint) MarshalRevealedJSON() ([]byte, error) {
+	type endpoint WebhookEndpoint
+	var headers map[string]string
+	if e.CustomHeaders != nil {
+		headers = make(map[string]string, len(e.CustomHeaders))
+		for k, v := range e.CustomHeaders {
+			headers[k] = v.Reveal()
+		}
+	}
+	return json.Marshal(struct {
+		endpoint
+		Secret        string            `json:"secret"`
+		CustomHeaders map[string]string `json:"custom_headers,omitempty"`
+	}{endpoint(e), e.Secret.Reveal(), headers})
+}
+
+// Secret holds a webhook signing secret. It prints and marshals as
+// "[REDACTED]" so it does not leak through logging; use Reveal for the value.
+//
+// Marshaling is lossy: json.Marshal never writes the secret, so a value
+// encoded and decoded again, e.g. through a cache, holds "[REDACTED]"
+// instead. Encode with WebhookEndpoint.MarshalRevealedJSON, or store Reveal
+// yourself, when the secret must be kept.
+type Secret string
+
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "[REDACTED]"
+}
+
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// WebhookHealth summarizes an endpoint's deliveries over the last 24 hours.
+type WebhookHealth struct {
+	WebhookID           string  `json:"webhook_id"`
+	SuccessRate         float64 `json:"success_rate"`
+	Deliveries          int     `json:"deliveries"`
+	ConsecutiveFailures int     `json:"consecutive_failures"`
+	LastSuccessAt       *string `json:"last_success_at,omitempty"`
+	LastFailureAt       *string `json:"last_failure_at,omitempty"`
+	Healthy             bool    `json:"healthy"`
+}
+
+// WebhookValidation is the result of a server-side probe of a webhook URL.
+// StatusCode is zero when no HTTP response was received.
+type WebhookValidation struct {
+	URL        string  `json:"url"`
+	Reachable  bool    `json:"reachable"`
+	StatusCode int     `json:"status_code"`
+	LatencyMs  int64   `json:"latency_ms"`
+	TLSError   *string `json:"tls_error,omitempty"`
+	Error      *string `json:"error,omitempty"`
 }
 
+func (v *WebhookValidation) Latency() time.Duration {
+	return time.Duration(v.LatencyMs) * time.Millisecond
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending    DeliveryStatus = "pending"
+	DeliveryDelivered  DeliveryStatus = "delivered"
+	DeliveryFailed     DeliveryStatus = "failed"
+	DeliveryDeadLetter DeliveryStatus = "dead_letter"
+)
+
+type WebhookDelivery struct {
+	ID            string         `json:"id"`
+	WebhookID     string         `json:"webhook_endpoint_id"`
+	EventID       string         `json:"event_id"`
+	Status        DeliveryStatus `json:"status"`
+	Attempts      int            `json:"attempts"`
+	LastAttemptAt *string        `json:"last_attempt_at,omitempty"`
+	NextRetryAt   *string        `json:"next_retry_at,omitempty"`
+	LastError     *string        `json:"last_error,omitempty"`
+	CreatedAt     string         `json:"created_at"`
+}
+
+// WebhookUpdate changes the settings of a webhook endpoint. Nil and empty
+// fields are left unchanged. The signing secret is kept, so deliveries to a
+// changed URL still verify.
+type WebhookUpdate struct {
+	URL                  *string          `json:"url,omitempty"`
+	Active               *bool            `json:"active,omitempty"`
+	AutoDisableThreshold *int             `json:"auto_disable_threshold,omitempty"`
+	SigningAlgorithm     SigningAlgorithm `json:"signing_algorithm,omitempty"`
+	// CustomHeaders, when non-empty, replaces the endpoint's custom headers.
+	CustomHeaders map[string]string `json:"custom_headers,omitempty"`
+}
+
+// EventType is the kind of subscription change an Event records.
+type EventType string
+
+const (
+	EventPurchase     EventType = "INITIAL_PURCHASE"
+	EventRenewal      EventType = "RENEWAL"
+	EventCancellation EventType = "CANCELLATION"
+	EventExpiration   EventType = "EXPIRATION"
+	EventRefund       EventType = "REFUND"
+	EventBillingIssue EventType = "BILLING_ISSUE_DETECTED"
+)
+
 type Event struct {
-	ID           string `json:"id"`
-	SubscriberID string `json:"subscriber_id"`
-	EventType    string `json:"event_type"`
-	Payload      string `json:"payload"`
-	CreatedAt    string `json:"created_at"`
+	ID            string    `json:"id"`
+	SubscriberID  string    `json:"subscriber_id"`
+	EventType     EventType `json:"event_type"`
+	Payload       string    `json:"payload"`
+	SchemaVersion string    `json:"schema_version,omitempty"`
+	CreatedAt     string    `json:"created_at"`
+}
+
+type BulkResult struct {
+	Results []BulkItemResult `json:"results"`
+}
+
+type BulkItemResult struct {
+	ID    string  `json:"id"`
+	OK    bool    `json:"ok"`
+	Error *string `json:"error,omitempty"`
+}
+
+func (r BulkResult) Failed() []BulkItemResult {
+	var failed []BulkItemResult
+	for _, item := range r.Results {
+		if !item.OK {
+			failed = append(failed, item)
+		}
+	}
+	return failed
+}
+
+// ReceiptSubmission is one receipt of a SubmitReceipts batch, recorded
+// against the app's product ProductID.
+type ReceiptSubmission struct {
+	AppID     string
+	AppUserID string
+	ProductID string
+	Receipt   Receipt
+}
+
+type ReceiptBatchResult struct {
+	Results []ReceiptItemResult `json:"results"`
+}
+
+// ReceiptItemResult is the outcome for the submission at Index in the batch.
+// Exactly one of Transaction and Error is set.
+type ReceiptItemResult struct {
+	Index       int           `json:"index"`
+	Transaction *Transaction  `json:"transaction,omitempty"`
+	Error       *ReceiptError `json:"error,omitempty"`
+}
+
+type ReceiptError struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable"`
+}
+
+func (e *ReceiptError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func (r ReceiptBatchResult) Failed() []ReceiptItemResult {
+	var failed []ReceiptItemResult
+	for _, item := range r.Results {
+		if item.Error != nil {
+			failed = append(failed, item)
+		}
+	}
+	return failed
+}
+
+// RetryableFailures returns the submissions from batch whose results failed
+// with a retryable error, in their original order, ready to be resubmitted.
+func (r ReceiptBatchResult) RetryableFailures(batch []ReceiptSubmission) []ReceiptSubmission {
+	retryable := make(map[int]bool)
+	for _, item := range r.Results {
+		if item.Error != nil && item.Error.Retryable {
+			retryable[item.Index] = true
+		}
+	}
+	var retry []ReceiptSubmission
+	for i, sub := range batch {
+		if retryable[i] {
+			retry = append(retry, sub)
+		}
+	}
+	return retry
 }
@@ -0,0 +1,29 @@
+package opencat
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTransactionGracePeriod(t *testing.T) {
+	end := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	retry := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	var tx Transaction
+	body := `{"id":"tx1","status":"grace_period","grace_period_start":"2024-01-01T00:00:00Z","grace_period_end":"` + end + `","next_retry_at":"` + retry + `"}`
+	if err := json.Unmarshal([]byte(body), &tx); err != nil {
+		t.Fatal(err)
+	}
+	if tx.GracePeriodStart == nil || !tx.GracePeriodStart.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected grace period start %v", tx.GracePeriodStart)
+	}
+	if d := tx.GracePeriodRemaining(); d < 47*time.Hour || d > 48*time.Hour {
+		t.Fatalf("unexpected remaining grace period %s", d)
+	}
+	if d := tx.UntilNextRetry(); d != 0 {
+		t.Fatalf("expected past retry to give 0, got %s", d)
+	}
+	if d := (&Transaction{}).GracePeriodRemaining(); d != 0 {
+		t.Fatalf("expected 0 outside grace period, got %s", d)
+	}
+}
@@ -0,0 +1,90 @@
+package opencat
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type offeringsCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*offeringsEntry
+}
+
+type offeringsEntry struct {
+	offering   *Offering
+	fetchedAt  time.Time
+	refreshing bool
+}
+
+// GetCurrentOffering returns the products currently offered by an app. With
+// WithOfferingsCache, a cached offering is returned without a request; once
+// it is older than the TTL it is still returned while a refresh runs in the
+// background.
+func (c *Client) GetCurrentOffering(appID string, opts ...CallOption) (*Offering, error) {
+	return c.GetCurrentOfferingContext(context.Background(), appID, opts...)
+}
+
+func (c *Client) GetCurrentOfferingContext(ctx context.Context, appID string, opts ...CallOption) (*Offering, error) {
+	oc := c.offerings
+	if oc == nil {
+		return c.fetchOffering(ctx, appID, opts)
+	}
+
+	oc.mu.Lock()
+	entry, ok := oc.entries[appID]
+	if ok {
+		if time.Since(entry.fetchedAt) >= oc.ttl && !entry.refreshing {
+			entry.refreshing = true
+			go c.refreshOffering(appID, entry)
+		}
+		oc.mu.Unlock()
+		return entry.offering, nil
+	}
+	oc.mu.Unlock()
+
+	offering, err := c.fetchOffering(ctx, appID, opts)
+	if err != nil {
+		return nil, err
+	}
+	oc.mu.Lock()
+	oc.entries[appID] = &offeringsEntry{offering: offering, fetchedAt: time.Now()}
+	oc.mu.Unlock()
+	return offering, nil
+}
+
+// InvalidateOfferings drops all cached offerings, so the next
+// GetCurrentOffering call fetches them again.
+func (c *Client) InvalidateOfferings() {
+	if c.offerings == nil {
+		return
+	}
+	c.offerings.mu.Lock()
+	c.offerings.entries = map[string]*offeringsEntry{}
+	c.offerings.mu.Unlock()
+}
+
+func (c *Client) refreshOffering(appID string, entry *offeringsEntry) {
+	offering, err := c.fetchOffering(context.Background(), appID, nil)
+
+	oc := c.offerings
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+	entry.refreshing = false
+	if err != nil || oc.entries[appID] != entry {
+		return
+	}
+	oc.entries[appID] = &offeringsEntry{offering: offering, fetchedAt: time.Now()}
+}
+
+func (c *Client) fetchOffering(ctx context.Context, appID string, opts []CallOption) (*Offering, error) {
+	var result Offering
+	err := c.request(ctx, "GET", fmt.Sprintf("/v1/apps/%s/offerings", appID), nil, nil, &result, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,72 @@
+package opencat
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetCurrentOfferingCache(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/apps/app-1/offerings" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		n := atomic.AddInt32(&calls, 1)
+		fmt.Fprintf(w, `{"offerings":[{"store_product_id":"pro_v%d","price_micros":9990000,"currency":"USD"}]}`, n)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithOfferingsCache(20*time.Millisecond))
+	get := func() string {
+		t.Helper()
+		o, err := c.GetCurrentOffering("app-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return o.Products[0].StoreProductID
+	}
+
+	if got := get(); got != "pro_v1" {
+		t.Fatalf("expected pro_v1, got %s", got)
+	}
+	if got := get(); got != "pro_v1" || atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("expected cached pro_v1, got %s after %d calls", got, calls)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	if got := get(); got != "pro_v1" {
+		t.Fatalf("expected stale pro_v1 while refreshing, got %s", got)
+	}
+	deadline := time.Now().Add(time.Second)
+	for get() != "pro_v2" {
+		if time.Now().After(deadline) {
+			t.Fatal("background refresh did not complete")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	c.InvalidateOfferings()
+	if got := get(); got != "pro_v3" {
+		t.Fatalf("expected fresh pro_v3 after invalidation, got %s", got)
+	}
+}
+
+func TestGetCurrentOfferingWithoutCache(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(`{"offerings":[]}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key")
+	c.GetCurrentOffering("app-1")
+	c.GetCurrentOffering("app-1")
+	if calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", calls)
+	}
+}
@@ -2,73 +2,402 @@ package opencat
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// Error is a response with an error status. Detail is the raw body; when the
+// body is a JSON error object, Code and Message hold its fields.
 type Error struct {
 	StatusCode int
+	Code       string
+	Message    string
 	Detail     string
+	// RequestID is the server's X-Request-Id for the failed call.
+	RequestID string
+
+	retryAfter time.Duration
 }
 
 func (e *Error) Error() string {
-	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Detail)
+	msg := e.Detail
+	if e.Message != "" {
+		msg = e.Message
+		if e.Code != "" {
+			msg = e.Code + ": " + e.Message
+		}
+	}
+	if e.RequestID != "" {
+		return fmt.Sprintf("HTTP %d: %s (request %s)", e.StatusCode, msg, e.RequestID)
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, msg)
+}
+
+const requestIDHeader = "X-Request-Id"
+
+func newError(resp *http.Response, body []byte) *Error {
+	e := &Error{
+		StatusCode: resp.StatusCode,
+		Detail:     string(body),
+		RequestID:  resp.Header.Get(requestIDHeader),
+		retryAfter: parseRetryAfter(resp.Header),
+	}
+	var structured struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &structured) == nil {
+		e.Code, e.Message = structured.Code, structured.Message
+	}
+	return e
+}
+
+// IsNotFound reports whether err is a 404 response.
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsRateLimited reports whether err is a 429 response.
+func IsRateLimited(err error) bool {
+	return hasStatus(err, http.StatusTooManyRequests)
+}
+
+// ServerError is returned for 5xx responses. It wraps the *Error, so
+// errors.As finds either.
+type ServerError struct {
+	Err *Error
+}
+
+func (e *ServerError) Error() string { return e.Err.Error() }
+func (e *ServerError) Unwrap() error { return e.Err }
+
+// ClientError is returned for 4xx responses. It wraps the *Error, so
+// errors.As finds either.
+type ClientError struct {
+	Err *Error
+}
+
+func (e *ClientError) Error() string { return e.Err.Error() }
+func (e *ClientError) Unwrap() error { return e.Err }
+
+// classifyError wraps an *Error in a ServerError or ClientError by status.
+func classifyError(err error) error {
+	apiErr, ok := err.(*Error)
+	switch {
+	case !ok:
+		return err
+	case apiErr.StatusCode >= 500:
+		return &ServerError{Err: apiErr}
+	case apiErr.StatusCode >= 400:
+		return &ClientError{Err: apiErr}
+	}
+	return err
+}
+
+// IsWrongRegion reports whether err is the server rejecting a request for a
+// resource that lives outside the client's region.
+func IsWrongRegion(err error) bool {
+	return hasStatus(err, http.StatusMisdirectedRequest)
+}
+
+func hasStatus(err error, code int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == code
 }
 
 type Client struct {
-	baseURL    string
-	apiKey     string
-	httpClient *http.Client
+	baseURL            string
+	apiKey             string
+	httpClient         *http.Client
+	customHTTPClient   bool
+	timeout            time.Duration
+	userAgent          string
+	headers            http.Header
+	contextHeaders     map[any]string
+	pathTimeouts       map[string]time.Duration
+	defaultQuery       url.Values
+	defaultProductType ProductType
+	createSubscriber   *bool
+	retry              *retryPolicy
+
+	region          string
+	sendDeadline    bool
+	methodOverride  bool
+	autoIdempotency bool
+	pins            []string
+	captureRaw      bool
+
+	fieldNaming FieldNaming
+
+	offerings      *offeringsCache
+	metadata       *metadataCache
+	stale          *staleCache
+	webhookKeys    *webhookKeyCache
+	tokens         *tokenCache
+	onIncompatible func(*ServerInfo)
+	auditLogger    AuditLogger
+
+	breaker  *breaker
+	throttle *throttle
+	sem      chan struct{}
+	inFlight atomic.Int64
 }
 
-func NewClient(serverURL, apiKey string) *Client {
-	return &Client{
+func NewClient(serverURL, apiKey string, opts ...Option) *Client {
+	c := &Client{
 		baseURL:    strings.TrimRight(serverURL, "/"),
 		apiKey:     apiKey,
 		httpClient: &http.Client{Timeout: 30 * time.Second},
+		webhookKeys: &webhookKeyCache{
+			ttl:        defaultWebhookKeyTTL,
+			minRefetch: webhookKeyRefetchInterval,
+		},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.timeout > 0 && !c.customHTTPClient {
+		c.httpClient.Timeout = c.timeout
+	}
+	if len(c.pins) > 0 {
+		c.httpClient = pinCertificates(c.httpClient, c.pins)
+	}
+	return c
+}
+
+// Region is the data region set with WithRegion, or "" if none.
+func (c *Client) Region() string {
+	return c.region
+}
+
+// InFlight reports how many requests the client is currently sending.
+func (c *Client) InFlight() int {
+	return int(c.inFlight.Load())
+}
+
+func (c *Client) acquire(ctx context.Context) error {
+	if c.sem != nil {
+		select {
+		case c.sem <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	c.inFlight.Add(1)
+	return nil
+}
+
+func (c *Client) release() {
+	c.inFlight.Add(-1)
+	if c.sem != nil {
+		<-c.sem
+	}
+}
+
+func (c *Client) request(ctx context.Context, method, path string, body any, query url.Values, result any, opts []CallOption) error {
+	co := newCallOptions(opts)
+	if co.response != nil {
+		*co.response = Response{}
+	}
+	u := c.requestURL(path, query, co)
+
+	// Body-field options only apply to calls that send a body. Options are
+	// shared between the calls of composite methods such as RecordPurchase,
+	// so they are dropped from reads rather than rejected.
+	readOnly := method == "GET" || method == "HEAD"
+	for k, v := range co.extra {
+		if c.fieldNaming == NormalizeSnakeCase {
+			k = snakeCase(k)
+		}
+		if _, ok := co.fields[k]; !ok {
+			co.fields[k] = v
+		}
+	}
+	if len(co.fields) > 0 && !readOnly {
+		merged, err := mergeFields(body, co.fields)
+		if err != nil {
+			return err
+		}
+		body = merged
+	}
+
+	var payload []byte
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = b
+	}
+
+	if c.autoIdempotency && !readOnly && co.headers.Get(idempotencyKeyHeader) == "" {
+		co.headers.Set(idempotencyKeyHeader, idempotencyKey(method, u, payload))
+	}
+
+	co.timeout = c.pathTimeout(path)
+	idempotent := readOnly || co.headers.Get(idempotencyKeyHeader) != ""
+	err := classifyError(c.withRetry(ctx, idempotent, func() error {
+		return c.do(ctx, method, u, payload, co, result)
+	}))
+	if co.audit != nil && !readOnly {
+		c.logAudit(ctx, co, payload, err)
 	}
+	return err
 }
 
-func (c *Client) request(method, path string, body any, query url.Values, result any) error {
+// requestURL returns the URL of path with query and the call's and client's
+// default query parameters, in that order of precedence.
+func (c *Client) requestURL(path string, query url.Values, co *callOptions) string {
+	if query == nil {
+		query = url.Values{}
+	}
+	for _, extra := range []url.Values{co.query, c.defaultQuery} {
+		for k, v := range extra {
+			if _, ok := query[k]; !ok {
+				query[k] = v
+			}
+		}
+	}
 	u := c.baseURL + path
 	if len(query) > 0 {
 		u += "?" + query.Encode()
 	}
+	return u
+}
 
-	var bodyReader io.Reader
+// pathTimeout returns the timeout of the longest WithPathTimeout pattern
+// matching path, or 0 if none does.
+func (c *Client) pathTimeout(path string) time.Duration {
+	var best string
+	var timeout time.Duration
+	for pattern, d := range c.pathTimeouts {
+		if len(pattern) > len(best) && matchPathPattern(pattern, path) {
+			best, timeout = pattern, d
+		}
+	}
+	return timeout
+}
+
+// matchPathPattern reports whether path starts with the segments of pattern,
+// where a "*" segment matches any single segment.
+func matchPathPattern(pattern, path string) bool {
+	want := strings.Split(strings.Trim(pattern, "/"), "/")
+	got := strings.Split(strings.Trim(path, "/"), "/")
+	if len(got) < len(want) {
+		return false
+	}
+	for i, seg := range want {
+		if seg != "*" && seg != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeFields adds fields to body, keeping the values body already has.
+func mergeFields(body any, fields map[string]any) (map[string]any, error) {
+	merged := map[string]any{}
 	if body != nil {
 		b, err := json.Marshal(body)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if err := json.Unmarshal(b, &merged); err != nil {
+			return nil, err
+		}
+	}
+	for k, v := range fields {
+		if _, ok := merged[k]; !ok {
+			merged[k] = v
 		}
-		bodyReader = bytes.NewReader(b)
 	}
+	return merged, nil
+}
 
-	req, err := http.NewRequest(method, u, bodyReader)
-	if err != nil {
-		return err
+func (c *Client) recordOutcome(ctx context.Context, err error, status int) {
+	if c.breaker == nil {
+		return
+	}
+	if ctx.Err() != nil {
+		c.breaker.record(nil)
+		return
+	}
+	ok := err == nil && status < 500
+	c.breaker.record(&ok)
+}
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+func idempotencyKey(method, u string, payload []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + u + "\n"))
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Client) do(ctx context.Context, method, u string, payload []byte, co *callOptions, result any) error {
+	hc := c.httpClient
+	reqCtx := ctx
+	if co.timeout > 0 {
+		// The path timeout replaces the client-wide one, which may be shorter.
+		var cancel context.CancelFunc
+		reqCtx, cancel = context.WithTimeout(ctx, co.timeout)
+		defer cancel()
+		scoped := *hc
+		scoped.Timeout = 0
+		hc = &scoped
 	}
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	req, err := c.newRequest(reqCtx, method, u, payload, co.headers)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
+	if err := c.admit(ctx); err != nil {
+		return err
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		c.release()
+		c.recordOutcome(ctx, err, 0)
+		return contextError(ctx, err)
+	}
 	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	c.release()
+	err = contextError(ctx, err)
+	c.observe(ctx, resp, err)
+	if co.audit != nil {
+		co.audit.status = resp.StatusCode
+	}
 	if err != nil {
 		return err
 	}
 
+	if co.response != nil {
+		co.response.StatusCode = resp.StatusCode
+		co.response.RequestID = resp.Header.Get(requestIDHeader)
+		if remaining, reset, ok := parseRateLimit(resp.Header); ok {
+			co.response.RateLimitRemaining, co.response.RateLimitReset = remaining, reset
+		}
+		if c.captureRaw {
+			co.response.Raw = json.RawMessage(data)
+		}
+	}
+
 	if resp.StatusCode >= 400 {
-		return &Error{StatusCode: resp.StatusCode, Detail: string(data)}
+		return newError(resp, data)
 	}
 	if result != nil && resp.StatusCode != 204 {
 		return json.Unmarshal(data, result)
@@ -76,104 +405,1359 @@ func (c *Client) request(method, path string, body any, query url.Values, result
 	return nil
 }
 
+// admit passes a request through the circuit breaker, the adaptive throttle,
+// and the concurrency limit. On success the caller holds a slot and must
+// release it once the response has been read.
+func (c *Client) admit(ctx context.Context) error {
+	if c.breaker != nil {
+		if err := c.breaker.allow(); err != nil {
+			return err
+		}
+	}
+	if c.throttle != nil {
+		if err := c.throttle.wait(ctx); err != nil {
+			c.recordOutcome(ctx, err, 0)
+			return err
+		}
+	}
+	if err := c.acquire(ctx); err != nil {
+		c.recordOutcome(ctx, err, 0)
+		return err
+	}
+	return nil
+}
+
+// observe feeds a response into the adaptive throttle and circuit breaker.
+func (c *Client) observe(ctx context.Context, resp *http.Response, err error) {
+	if c.throttle != nil {
+		c.throttle.update(resp.Header)
+	}
+	c.recordOutcome(ctx, err, resp.StatusCode)
+}
+
+// contextError returns ctx.Err() in place of err once the caller's context
+// is done, so an aborted call reports why it was aborted.
+func contextError(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// newRequest builds a request carrying the client's authentication and
+// headers, then the context-mapped and per-call headers.
+func (c *Client) newRequest(ctx context.Context, method, u string, payload []byte, headers http.Header) (*http.Request, error) {
+	var bodyReader io.Reader
+	if payload != nil {
+		bodyReader = bytes.NewReader(payload)
+	}
+	wireMethod := method
+	if c.methodOverride && (method == "PATCH" || method == "DELETE") {
+		wireMethod = "POST"
+	}
+	req, err := http.NewRequestWithContext(ctx, wireMethod, u, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	if wireMethod != method {
+		req.Header.Set("X-HTTP-Method-Override", method)
+	}
+	if c.region != "" {
+		req.Header.Set("X-Data-Region", c.region)
+	}
+	for k, v := range c.headers {
+		req.Header[k] = v
+	}
+	for key, name := range c.contextHeaders {
+		if v := ctx.Value(key); v != nil {
+			if s := fmt.Sprint(v); s != "" {
+				req.Header.Set(name, s)
+			}
+		}
+	}
+	for k, v := range headers {
+		req.Header[k] = v
+	}
+	if deadline, ok := ctx.Deadline(); ok && c.sendDeadline {
+		req.Header.Set("X-Request-Timeout-Ms", strconv.FormatInt(time.Until(deadline).Milliseconds(), 10))
+	}
+	return req, nil
+}
+
+// -- server --
+
+// APIVersion is the server API version this SDK is written against.
+const APIVersion = "1"
+
+// ServerInfo reports the version of the connected server. If the server's
+// API version differs in major version from APIVersion, the hook set with
+// WithIncompatibleServerHook is called before returning.
+func (c *Client) ServerInfo(opts ...CallOption) (*ServerInfo, error) {
+	return c.ServerInfoContext(context.Background(), opts...)
+}
+
+func (c *Client) ServerInfoContext(ctx context.Context, opts ...CallOption) (*ServerInfo, error) {
+	var result ServerInfo
+	if err := c.request(ctx, "GET", "/health", nil, nil, &result, opts); err != nil {
+		return nil, err
+	}
+	if c.onIncompatible != nil && !result.Compatible() {
+		c.onIncompatible(&result)
+	}
+	return &result, nil
+}
+
 // -- apps --
 
-func (c *Client) CreateApp(name, platform, bundleID string) (*App, error) {
+func (c *Client) CreateApp(name, platform, bundleID string, opts ...CallOption) (*App, error) {
+	return c.CreateAppContext(context.Background(), name, platform, bundleID, opts...)
+}
+
+func (c *Client) CreateAppContext(ctx context.Context, name, platform, bundleID string, opts ...CallOption) (*App, error) {
+	opts = c.audited("CreateApp", nil, opts)
 	var result App
-	err := c.request("POST", "/v1/apps", map[string]string{
+	err := c.request(ctx, "POST", "/v1/apps", map[string]string{
 		"name": name, "platform": platform, "bundle_id": bundleID,
-	}, nil, &result)
+	}, nil, &result, opts)
 	return &result, err
 }
 
-func (c *Client) ListApps() ([]App, error) {
+func (c *Client) ListApps(opts ...CallOption) ([]App, error) {
+	return c.ListAppsContext(context.Background(), opts...)
+}
+
+func (c *Client) ListAppsContext(ctx context.Context, opts ...CallOption) ([]App, error) {
 	var result []App
-	err := c.request("GET", "/v1/apps", nil, nil, &result)
+	err := c.request(ctx, "GET", "/v1/apps", nil, nil, &result, opts)
 	return result, err
 }
 
+// GetApp fetches a single app. An unknown appID is an error for which
+// IsNotFound reports true.
+func (c *Client) GetApp(appID string, opts ...CallOption) (*App, error) {
+	return c.GetAppContext(context.Background(), appID, opts...)
+}
+
+func (c *Client) GetAppContext(ctx context.Context, appID string, opts ...CallOption) (*App, error) {
+	var result App
+	if err := c.request(ctx, "GET", "/v1/apps/"+url.PathEscape(appID), nil, nil, &result, opts); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DeleteApp deletes an app with its products, entitlements, and subscribers.
+// Deleting an app that does not exist fails with an error for which
+// IsNotFound reports true.
+func (c *Client) DeleteApp(appID string, opts ...CallOption) error {
+	return c.DeleteAppContext(context.Background(), appID, opts...)
+}
+
+func (c *Client) DeleteAppContext(ctx context.Context, appID string, opts ...CallOption) error {
+	opts = c.audited("DeleteApp", map[string]string{"app_id": appID}, opts)
+	err := c.request(ctx, "DELETE", "/v1/apps/"+url.PathEscape(appID), nil, nil, nil, opts)
+	if err == nil {
+		c.InvalidateMetadata(appID)
+	}
+	return err
+}
+
+// GetAppFacets returns the stores, product types, and entitlement names
+// actually in use by an app, computed by the server.
+func (c *Client) GetAppFacets(appID string, opts ...CallOption) (*AppFacets, error) {
+	return c.GetAppFacetsContext(context.Background(), appID, opts...)
+}
+
+func (c *Client) GetAppFacetsContext(ctx context.Context, appID string, opts ...CallOption) (*AppFacets, error) {
+	var result AppFacets
+	err := c.request(ctx, "GET", fmt.Sprintf("/v1/apps/%s/facets", appID), nil, nil, &result, opts)
+	return &result, err
+}
+
+// GetAppConfig returns an overview of everything configured for an app.
+// Store credentials are reported as configured or not, never their values.
+func (c *Client) GetAppConfig(appID string, opts ...CallOption) (*AppConfig, error) {
+	return c.GetAppConfigContext(context.Background(), appID, opts...)
+}
+
+func (c *Client) GetAppConfigContext(ctx context.Context, appID string, opts ...CallOption) (*AppConfig, error) {
+	var result AppConfig
+	err := c.request(ctx, "GET", fmt.Sprintf("/v1/apps/%s/config", appID), nil, nil, &result, opts)
+	return &result, err
+}
+
 // -- subscribers --
 
-func (c *Client) GetSubscriber(appUserID string) (*SubscriberInfo, error) {
+// GetSubscriber fetches a subscriber with its entitlements and transactions.
+// It never creates the subscriber: an unknown appUserID is a 404 *Error.
+// Subscribers are created when a receipt is first submitted for them. With
+// WithStaleOnError, a failed fetch may instead return the last good state,
+// marked Stale.
+func (c *Client) GetSubscriber(appUserID string, opts ...CallOption) (*SubscriberInfo, error) {
+	return c.GetSubscriberContext(context.Background(), appUserID, opts...)
+}
+
+func (c *Client) GetSubscriberContext(ctx context.Context, appUserID string, opts ...CallOption) (*SubscriberInfo, error) {
 	var result SubscriberInfo
-	err := c.request("GET", "/v1/subscribers/"+url.PathEscape(appUserID), nil, nil, &result)
+	err := c.request(ctx, "GET", "/v1/subscribers/"+url.PathEscape(appUserID), nil, nil, &result, opts)
+	if c.stale == nil || changesResponse(opts) {
+		return &result, err
+	}
+	if err == nil {
+		c.stale.store(appUserID, &result)
+		return &result, nil
+	}
+	if serveStale(err) {
+		if info, ok := c.stale.load(appUserID); ok {
+			return info, nil
+		}
+	}
 	return &result, err
 }
 
-// -- products --
+// CreateAnonymousSubscriber creates a subscriber for a user who has not
+// logged in yet. The server generates its app user ID from 128 random bits,
+// prefixed with AnonymousIDPrefix.
+func (c *Client) CreateAnonymousSubscriber(appID string, opts ...CallOption) (*Subscriber, error) {
+	return c.CreateAnonymousSubscriberContext(context.Background(), appID, opts...)
+}
 
-func (c *Client) CreateProduct(appID, storeProductID, productType string, entitlementIDs []string) (*Product, error) {
-	var result Product
-	err := c.request("POST", fmt.Sprintf("/v1/apps/%s/products", appID), map[string]any{
-		"store_product_id": storeProductID,
-		"product_type":     productType,
-		"entitlement_ids":  entitlementIDs,
-	}, nil, &result)
+func (c *Client) CreateAnonymousSubscriberContext(ctx context.Context, appID string, opts ...CallOption) (*Subscriber, error) {
+	opts = c.audited("CreateAnonymousSubscriber", map[string]string{"app_id": appID}, opts)
+	var result Subscriber
+	err := c.request(ctx, "POST", fmt.Sprintf("/v1/apps/%s/subscribers/anonymous", appID), nil, nil, &result, opts)
 	return &result, err
 }
 
-func (c *Client) ListProducts(appID string) ([]Product, error) {
-	var result []Product
-	err := c.request("GET", fmt.Sprintf("/v1/apps/%s/products", appID), nil, nil, &result)
-	return result, err
+// SubscriberExists reports whether the server knows appUserID, without
+// creating it or fetching its data.
+func (c *Client) SubscriberExists(appUserID string, opts ...CallOption) (bool, error) {
+	return c.SubscriberExistsContext(context.Background(), appUserID, opts...)
 }
 
-// -- entitlements --
+func (c *Client) SubscriberExistsContext(ctx context.Context, appUserID string, opts ...CallOption) (bool, error) {
+	err := c.request(ctx, "HEAD", "/v1/subscribers/"+url.PathEscape(appUserID), nil, nil, nil, opts)
+	if hasStatus(err, http.StatusNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
 
-func (c *Client) CreateEntitlement(appID, name string, description *string) (*Entitlement, error) {
-	body := map[string]any{"name": name}
-	if description != nil {
-		body["description"] = *description
+// GetActiveEntitlements returns the subscriber's entitlements keyed by name,
+// true for those active now. Entitlements in a billing grace period count as
+// active, matching GetSubscriber.
+func (c *Client) GetActiveEntitlements(appUserID string, opts ...CallOption) (map[string]bool, error) {
+	return c.GetActiveEntitlementsContext(context.Background(), appUserID, opts...)
+}
+
+func (c *Client) GetActiveEntitlementsContext(ctx context.Context, appUserID string, opts ...CallOption) (map[string]bool, error) {
+	var result struct {
+		Entitlements map[string]bool `json:"entitlements"`
 	}
-	var result Entitlement
-	err := c.request("POST", fmt.Sprintf("/v1/apps/%s/entitlements", appID), body, nil, &result)
+	err := c.request(ctx, "GET", "/v1/subscribers/"+url.PathEscape(appUserID)+"/entitlements/active", nil, nil, &result, opts)
+	if result.Entitlements == nil {
+		result.Entitlements = map[string]bool{}
+	}
+	return result.Entitlements, err
+}
+
+// SubscriberEntitlementChangesSince lists the entitlement grants,
+// revocations and expirations of a subscriber after since, oldest first. It
+// returns an empty slice if nothing changed.
+func (c *Client) SubscriberEntitlementChangesSince(appUserID string, since time.Time, opts ...CallOption) ([]EntitlementChange, error) {
+	return c.SubscriberEntitlementChangesSinceContext(context.Background(), appUserID, since, opts...)
+}
+
+func (c *Client) SubscriberEntitlementChangesSinceContext(ctx context.Context, appUserID string, since time.Time, opts ...CallOption) ([]EntitlementChange, error) {
+	q := url.Values{"since": {since.UTC().Format(time.RFC3339Nano)}}
+	var result []EntitlementChange
+	err := c.request(ctx, "GET", "/v1/subscribers/"+url.PathEscape(appUserID)+"/entitlements/changes", nil, q, &result, opts)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		result = []EntitlementChange{}
+	}
+	return result, nil
+}
+
+// GetUserEntitlementsAcrossApps fetches the entitlements of appUserID in each
+// of appIDs in one request, keyed by app ID. An app the user is unknown to
+// maps to an empty slice.
+func (c *Client) GetUserEntitlementsAcrossApps(appUserID string, appIDs []string, opts ...CallOption) (map[string][]EntitlementInfo, error) {
+	return c.GetUserEntitlementsAcrossAppsContext(context.Background(), appUserID, appIDs, opts...)
+}
+
+func (c *Client) GetUserEntitlementsAcrossAppsContext(ctx context.Context, appUserID string, appIDs []string, opts ...CallOption) (map[string][]EntitlementInfo, error) {
+	q := url.Values{"app_ids": {strings.Join(appIDs, ",")}}
+	var result struct {
+		Apps map[string][]EntitlementInfo `json:"apps"`
+	}
+	err := c.request(ctx, "GET", "/v1/subscribers/"+url.PathEscape(appUserID)+"/entitlements", nil, q, &result, opts)
+	if err != nil {
+		return nil, err
+	}
+	byApp := make(map[string][]EntitlementInfo, len(appIDs))
+	for _, appID := range appIDs {
+		byApp[appID] = result.Apps[appID]
+		if byApp[appID] == nil {
+			byApp[appID] = []EntitlementInfo{}
+		}
+	}
+	return byApp, nil
+}
+
+// RevalidateSubscriber re-runs store verification of the subscriber's stored
+// receipts and returns the refreshed state. Calling it repeatedly is safe.
+func (c *Client) RevalidateSubscriber(appUserID string, opts ...CallOption) (*SubscriberInfo, error) {
+	return c.RevalidateSubscriberContext(context.Background(), appUserID, opts...)
+}
+
+func (c *Client) RevalidateSubscriberContext(ctx context.Context, appUserID string, opts ...CallOption) (*SubscriberInfo, error) {
+	opts = c.audited("RevalidateSubscriber", map[string]string{"app_user_id": appUserID}, opts)
+	var result SubscriberInfo
+	err := c.request(ctx, "POST", "/v1/subscribers/"+url.PathEscape(appUserID)+"/revalidate", nil, nil, &result, opts)
 	return &result, err
 }
 
-func (c *Client) ListEntitlements(appID string) ([]Entitlement, error) {
-	var result []Entitlement
-	err := c.request("GET", fmt.Sprintf("/v1/apps/%s/entitlements", appID), nil, nil, &result)
-	return result, err
+func (c *Client) SetSubscriberProfile(appUserID string, profile SubscriberProfile, opts ...CallOption) error {
+	return c.SetSubscriberProfileContext(context.Background(), appUserID, profile, opts...)
 }
 
-// -- receipts --
+func (c *Client) SetSubscriberProfileContext(ctx context.Context, appUserID string, profile SubscriberProfile, opts ...CallOption) error {
+	opts = c.audited("SetSubscriberProfile", map[string]string{"app_user_id": appUserID}, opts)
+	if profile.Email != nil {
+		if err := ValidateEmail(*profile.Email); err != nil {
+			return err
+		}
+	}
+	return c.request(ctx, "PATCH", "/v1/subscribers/"+url.PathEscape(appUserID)+"/profile", profile, nil, nil, opts)
+}
 
-func (c *Client) SubmitReceipt(appID, appUserID, store, receiptData, productID string) (*Transaction, error) {
-	var result Transaction
-	err := c.request("POST", "/v1/receipts", map[string]string{
-		"app_id":       appID,
-		"app_user_id":  appUserID,
-		"store":        store,
-		"receipt_data": receiptData,
-		"product_id":   productID,
-	}, nil, &result)
+// SetSubscriberAttribution replaces the acquisition attribution recorded for
+// a subscriber.
+func (c *Client) SetSubscriberAttribution(appUserID string, attr Attribution, opts ...CallOption) error {
+	return c.SetSubscriberAttributionContext(context.Background(), appUserID, attr, opts...)
+}
+
+func (c *Client) SetSubscriberAttributionContext(ctx context.Context, appUserID string, attr Attribution, opts ...CallOption) error {
+	opts = c.audited("SetSubscriberAttribution", map[string]string{"app_user_id": appUserID}, opts)
+	return c.request(ctx, "PUT", "/v1/subscribers/"+url.PathEscape(appUserID)+"/attribution", attr, nil, nil, opts)
+}
+
+func (c *Client) GetSubscriberLTV(appUserID string, opts ...CallOption) (*LTV, error) {
+	return c.GetSubscriberLTVContext(context.Background(), appUserID, opts...)
+}
+
+func (c *Client) GetSubscriberLTVContext(ctx context.Context, appUserID string, opts ...CallOption) (*LTV, error) {
+	var result LTV
+	err := c.request(ctx, "GET", "/v1/subscribers/"+url.PathEscape(appUserID)+"/ltv", nil, nil, &result, opts)
 	return &result, err
 }
 
-// -- webhooks --
+func (c *Client) GetSubscriptionHistory(appUserID, productID string, opts ...CallOption) (*SubscriptionHistory, error) {
+	return c.GetSubscriptionHistoryContext(context.Background(), appUserID, productID, opts...)
+}
 
-func (c *Client) CreateWebhook(appID, webhookURL string) (*WebhookEndpoint, error) {
-	var result WebhookEndpoint
-	err := c.request("POST", "/v1/webhooks", map[string]string{
-		"app_id": appID, "url": webhookURL,
-	}, nil, &result)
+func (c *Client) GetSubscriptionHistoryContext(ctx context.Context, appUserID, productID string, opts ...CallOption) (*SubscriptionHistory, error) {
+	var result SubscriptionHistory
+	path := fmt.Sprintf("/v1/subscribers/%s/subscriptions/%s/history", url.PathEscape(appUserID), url.PathEscape(productID))
+	err := c.request(ctx, "GET", path, nil, nil, &result, opts)
 	return &result, err
 }
 
-func (c *Client) ListWebhooks() ([]WebhookEndpoint, error) {
-	var result []WebhookEndpoint
-	err := c.request("GET", "/v1/webhooks", nil, nil, &result)
-	return result, err
+// GrantEntitlement gives a subscriber an entitlement for duration, extending
+// any active grant of the same entitlement. With DryRun the server computes
+// the result without saving it and the returned grant is marked Preview.
+func (c *Client) GrantEntitlement(appUserID, entitlementID string, duration time.Duration, opts ...CallOption) (*EntitlementGrant, error) {
+	return c.GrantEntitlementContext(context.Background(), appUserID, entitlementID, duration, opts...)
 }
 
-// -- events --
+func (c *Client) GrantEntitlementContext(ctx context.Context, appUserID, entitlementID string, duration time.Duration, opts ...CallOption) (*EntitlementGrant, error) {
+	opts = c.audited("GrantEntitlement", map[string]string{"app_user_id": appUserID, "entitlement_id": entitlementID}, opts)
+	var result EntitlementGrant
+	path := fmt.Sprintf("/v1/subscribers/%s/entitlements/%s/grant", url.PathEscape(appUserID), url.PathEscape(entitlementID))
+	err := c.request(ctx, "POST", path, map[string]any{
+		"duration_seconds": int64(duration / time.Second),
+	}, nil, &result, opts)
+	if err == nil && newCallOptions(opts).query.Get("dry_run") == "true" {
+		result.Preview = true
+	}
+	return &result, err
+}
+
+// ListSubscribers lists an app's subscribers first seen between from and to.
+// A zero from or to leaves that end open. Page with WithLimit and WithCursor.
+func (c *Client) ListSubscribers(appID string, from, to time.Time, opts ...ListOption) ([]SubscriberInfo, error) {
+	return c.ListSubscribersContext(context.Background(), appID, from, to, opts...)
+}
 
-func (c *Client) ListEvents(cursor string) ([]Event, error) {
+func (c *Client) ListSubscribersContext(ctx context.Context, appID string, from, to time.Time, opts ...ListOption) ([]SubscriberInfo, error) {
 	q := url.Values{}
-	if cursor != "" {
-		q.Set("since", cursor)
-	}
-	var result []Event
-	err := c.request("GET", "/v1/events", nil, q, &result)
+	setDateRange(q, from, to)
+	var result []SubscriberInfo
+	err := c.request(ctx, "GET", fmt.Sprintf("/v1/apps/%s/subscribers", appID), nil, q, &result, opts)
 	return result, err
 }
+
+// How many subscribers BulkDeleteSubscribers deletes per request.
+const bulkDeleteChunkSize = 100
+
+// BulkDeleteSubscribers deletes subscribers of an app with their
+// transactions, in requests of up to 100 IDs. It stops between requests
+// when ctx is done, returning the results so far with the context's error.
+func (c *Client) BulkDeleteSubscribers(appID string, appUserIDs []string, opts ...CallOption) (BulkResult, error) {
+	return c.BulkDeleteSubscribersContext(context.Background(), appID, appUserIDs, opts...)
+}
+
+func (c *Client) BulkDeleteSubscribersContext(ctx context.Context, appID string, appUserIDs []string, opts ...CallOption) (BulkResult, error) {
+	opts = c.audited("BulkDeleteSubscribers", map[string]string{"app_id": appID}, opts)
+	var all BulkResult
+	for start := 0; start < len(appUserIDs); start += bulkDeleteChunkSize {
+		if err := ctx.Err(); err != nil {
+			return all, err
+		}
+		chunk := appUserIDs[start:min(start+bulkDeleteChunkSize, len(appUserIDs))]
+		result, err := c.deleteSubscribers(ctx, appID, map[string]any{"app_user_ids": chunk}, opts)
+		all.Results = append(all.Results, result.Results...)
+		if err != nil {
+			return all, err
+		}
+	}
+	return all, nil
+}
+
+// DeleteSubscribersWithPrefix deletes every subscriber of an app whose
+// app_user_id starts with prefix. The server matches and deletes them in one
+// call; an empty prefix is rejected so a mistake cannot wipe the whole app.
+func (c *Client) DeleteSubscribersWithPrefix(appID, prefix string, opts ...CallOption) (BulkResult, error) {
+	return c.DeleteSubscribersWithPrefixContext(context.Background(), appID, prefix, opts...)
+}
+
+func (c *Client) DeleteSubscribersWithPrefixContext(ctx context.Context, appID, prefix string, opts ...CallOption) (BulkResult, error) {
+	opts = c.audited("DeleteSubscribersWithPrefix", map[string]string{"app_id": appID}, opts)
+	if prefix == "" {
+		return BulkResult{}, errors.New("opencat: app user ID prefix is required")
+	}
+	return c.deleteSubscribers(ctx, appID, map[string]any{"app_user_id_prefix": prefix}, opts)
+}
+
+func (c *Client) deleteSubscribers(ctx context.Context, appID string, body map[string]any, opts []CallOption) (BulkResult, error) {
+	var result BulkResult
+	err := c.request(ctx, "POST", fmt.Sprintf("/v1/apps/%s/subscribers/delete", appID), body, nil, &result, opts)
+	return result, err
+}
+
+func (c *Client) ListExpiringSubscribers(appID string, within time.Duration, opts ...ListOption) ([]SubscriberInfo, error) {
+	return c.ListExpiringSubscribersContext(context.Background(), appID, within, opts...)
+}
+
+func (c *Client) ListExpiringSubscribersContext(ctx context.Context, appID string, within time.Duration, opts ...ListOption) ([]SubscriberInfo, error) {
+	q := url.Values{}
+	q.Set("expires_before", time.Now().Add(within).UTC().Format(time.RFC3339))
+	q.Set("will_renew", "false")
+	var result []SubscriberInfo
+	err := c.request(ctx, "GET", fmt.Sprintf("/v1/apps/%s/subscribers", appID), nil, q, &result, opts)
+	return result, err
+}
+
+// -- products --
+
+// CreateProduct creates a product. An empty productType means the client's
+// WithDefaultProductType.
+func (c *Client) CreateProduct(appID, storeProductID, productType string, entitlementIDs []string, opts ...CallOption) (*Product, error) {
+	return c.CreateProductContext(context.Background(), appID, storeProductID, productType, entitlementIDs, opts...)
+}
+
+func (c *Client) CreateProductContext(ctx context.Context, appID, storeProductID, productType string, entitlementIDs []string, opts ...CallOption) (*Product, error) {
+	opts = c.audited("CreateProduct", map[string]string{"app_id": appID}, opts)
+	if productType == "" {
+		productType = string(c.defaultProductType)
+	}
+	if !ProductType(productType).Valid() {
+		return nil, fmt.Errorf("opencat: unknown product type %q", productType)
+	}
+	co := newCallOptions(opts)
+	if co.upsert {
+		if existing, err := c.findProduct(ctx, appID, storeProductID, co); err != nil || existing != nil {
+			return existing, err
+		}
+	}
+	var result Product
+	err := c.request(ctx, "POST", fmt.Sprintf("/v1/apps/%s/products", appID), map[string]any{
+		"store_product_id": storeProductID,
+		"product_type":     productType,
+		"entitlement_ids":  entitlementIDs,
+	}, nil, &result, opts)
+	if co.upsert && hasStatus(err, http.StatusConflict) {
+		c.InvalidateMetadata(appID)
+		if existing, findErr := c.findProduct(ctx, appID, storeProductID, co); findErr == nil && existing != nil {
+			return existing, nil
+		}
+	}
+	if err == nil {
+		co.setCreated()
+		c.InvalidateMetadata(appID)
+	}
+	return &result, err
+}
+
+// findProduct looks up the product an upsert would create, with only the
+// lookup options of co.
+func (c *Client) findProduct(ctx context.Context, appID, storeProductID string, co *callOptions) (*Product, error) {
+	products, err := c.ListProductsContext(ctx, appID, co.lookupOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range products {
+		if products[i].StoreProductID == storeProductID {
+			co.setExisting()
+			return &products[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *Client) ListProducts(appID string, opts ...CallOption) ([]Product, error) {
+	return c.ListProductsContext(context.Background(), appID, opts...)
+}
+
+func (c *Client) ListProductsContext(ctx context.Context, appID string, opts ...CallOption) ([]Product, error) {
+	return c.listProductsCached(ctx, appID, opts)
+}
+
+var productUpdateFields = map[string]bool{
+	"product_type":    true,
+	"entitlement_ids": true,
+	"display_name":    true,
+	"description":     true,
+}
+
+// UpdateProduct changes the product fields named in mask, by their JSON
+// names, to their values in update. Other fields are not sent, so concurrent
+// updates of different fields do not overwrite each other. Every masked field
+// must be set in update.
+func (c *Client) UpdateProduct(appID, productID string, update ProductUpdate, mask []string, opts ...CallOption) (*Product, error) {
+	return c.UpdateProductContext(context.Background(), appID, productID, update, mask, opts...)
+}
+
+func (c *Client) UpdateProductContext(ctx context.Context, appID, productID string, update ProductUpdate, mask []string, opts ...CallOption) (*Product, error) {
+	opts = c.audited("UpdateProduct", map[string]string{"app_id": appID, "product_id": productID}, opts)
+	if len(mask) == 0 {
+		return nil, errors.New("opencat: update mask is empty")
+	}
+	if update.ProductType != "" && !update.ProductType.Valid() {
+		return nil, fmt.Errorf("opencat: unknown product type %q", update.ProductType)
+	}
+	values, err := mergeFields(update, nil)
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]any{"update_mask": mask}
+	for _, field := range mask {
+		if !productUpdateFields[field] {
+			return nil, fmt.Errorf("opencat: unknown product field %q in update mask", field)
+		}
+		v, ok := values[field]
+		if !ok {
+			return nil, fmt.Errorf("opencat: product field %q is in the update mask but not set", field)
+		}
+		body[field] = v
+	}
+	var result Product
+	err = c.request(ctx, "PATCH", fmt.Sprintf("/v1/apps/%s/products/%s", appID, productID), body, nil, &result, opts)
+	if err == nil {
+		c.InvalidateMetadata(appID)
+	}
+	return &result, err
+}
+
+// DeleteProduct deletes a product. A product that does not exist is an error
+// for which IsNotFound reports true.
+func (c *Client) DeleteProduct(appID, productID string, opts ...CallOption) error {
+	return c.DeleteProductContext(context.Background(), appID, productID, opts...)
+}
+
+func (c *Client) DeleteProductContext(ctx context.Context, appID, productID string, opts ...CallOption) error {
+	opts = c.audited("DeleteProduct", map[string]string{"app_id": appID, "product_id": productID}, opts)
+	err := c.request(ctx, "DELETE", fmt.Sprintf("/v1/apps/%s/products/%s", appID, productID), nil, nil, nil, opts)
+	if err == nil {
+		c.InvalidateMetadata(appID)
+	}
+	return err
+}
+
+// GetProductWithEntitlements fetches a product together with the
+// entitlements it grants, in one request.
+func (c *Client) GetProductWithEntitlements(appID, productID string, opts ...CallOption) (*Product, []Entitlement, error) {
+	return c.GetProductWithEntitlementsContext(context.Background(), appID, productID, opts...)
+}
+
+func (c *Client) GetProductWithEntitlementsContext(ctx context.Context, appID, productID string, opts ...CallOption) (*Product, []Entitlement, error) {
+	var result struct {
+		Product      Product       `json:"product"`
+		Entitlements []Entitlement `json:"entitlements"`
+	}
+	q := url.Values{"include": {"entitlements"}}
+	if err := c.request(ctx, "GET", fmt.Sprintf("/v1/apps/%s/products/%s", appID, productID), nil, q, &result, opts); err != nil {
+		return nil, nil, err
+	}
+	return &result.Product, result.Entitlements, nil
+}
+
+// BulkUpdateProductEntitlements replaces the entitlements of several products,
+// keyed by product ID, in a single call.
+func (c *Client) BulkUpdateProductEntitlements(appID string, updates map[string][]string, opts ...CallOption) (BulkResult, error) {
+	return c.BulkUpdateProductEntitlementsContext(context.Background(), appID, updates, opts...)
+}
+
+func (c *Client) BulkUpdateProductEntitlementsContext(ctx context.Context, appID string, updates map[string][]string, opts ...CallOption) (BulkResult, error) {
+	opts = c.audited("BulkUpdateProductEntitlements", map[string]string{"app_id": appID}, opts)
+	items := make([]map[string]any, 0, len(updates))
+	for productID, entitlementIDs := range updates {
+		items = append(items, map[string]any{
+			"product_id":      productID,
+			"entitlement_ids": entitlementIDs,
+		})
+	}
+	var result BulkResult
+	err := c.request(ctx, "POST", fmt.Sprintf("/v1/apps/%s/products/entitlements", appID), map[string]any{
+		"updates": items,
+	}, nil, &result, opts)
+	if err == nil {
+		c.InvalidateMetadata(appID)
+	}
+	return result, err
+}
+
+// -- entitlements --
+
+func (c *Client) CreateEntitlement(appID, name string, description *string, opts ...CallOption) (*Entitlement, error) {
+	return c.CreateEntitlementContext(context.Background(), appID, name, description, opts...)
+}
+
+func (c *Client) CreateEntitlementContext(ctx context.Context, appID, name string, description *string, opts ...CallOption) (*Entitlement, error) {
+	opts = c.audited("CreateEntitlement", map[string]string{"app_id": appID}, opts)
+	body := map[string]any{"name": name}
+	if description != nil {
+		body["description"] = *description
+	}
+	co := newCallOptions(opts)
+	if co.validate {
+		if err := ValidateEntitlementName(name); err != nil {
+			return nil, err
+		}
+	}
+	if co.upsert {
+		if existing, err := c.findEntitlement(ctx, appID, name, co); err != nil || existing != nil {
+			return existing, err
+		}
+	}
+	var result Entitlement
+	err := c.request(ctx, "POST", fmt.Sprintf("/v1/apps/%s/entitlements", appID), body, nil, &result, opts)
+	if co.upsert && hasStatus(err, http.StatusConflict) {
+		c.InvalidateMetadata(appID)
+		if existing, findErr := c.findEntitlement(ctx, appID, name, co); findErr == nil && existing != nil {
+			return existing, nil
+		}
+	}
+	if err == nil {
+		co.setCreated()
+		c.InvalidateMetadata(appID)
+	}
+	return &result, err
+}
+
+// findEntitlement looks up the entitlement an upsert would create, with only
+// the lookup options of co.
+func (c *Client) findEntitlement(ctx context.Context, appID, name string, co *callOptions) (*Entitlement, error) {
+	entitlements, err := c.ListEntitlementsContext(ctx, appID, co.lookupOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entitlements {
+		if entitlements[i].Name == name {
+			co.setExisting()
+			return &entitlements[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *Client) ListEntitlements(appID string, opts ...CallOption) ([]Entitlement, error) {
+	return c.ListEntitlementsContext(context.Background(), appID, opts...)
+}
+
+func (c *Client) ListEntitlementsContext(ctx context.Context, appID string, opts ...CallOption) ([]Entitlement, error) {
+	return c.listEntitlementsCached(ctx, appID, opts)
+}
+
+// UpdateEntitlement changes the fields set in update, leaving the others as
+// they are.
+func (c *Client) UpdateEntitlement(appID, entitlementID string, update EntitlementUpdate, opts ...CallOption) (*Entitlement, error) {
+	return c.UpdateEntitlementContext(context.Background(), appID, entitlementID, update, opts...)
+}
+
+func (c *Client) UpdateEntitlementContext(ctx context.Context, appID, entitlementID string, update EntitlementUpdate, opts ...CallOption) (*Entitlement, error) {
+	opts = c.audited("UpdateEntitlement", map[string]string{"app_id": appID, "entitlement_id": entitlementID}, opts)
+	if update.Name != nil && newCallOptions(opts).validate {
+		if err := ValidateEntitlementName(*update.Name); err != nil {
+			return nil, err
+		}
+	}
+	var result Entitlement
+	err := c.request(ctx, "PATCH", fmt.Sprintf("/v1/apps/%s/entitlements/%s", appID, entitlementID), update, nil, &result, opts)
+	if err == nil {
+		c.InvalidateMetadata(appID)
+	}
+	return &result, err
+}
+
+// ListEntitlementSubscribers lists the subscribers holding an entitlement
+// now, or at the time given by WithAsOf.
+func (c *Client) ListEntitlementSubscribers(appID, entitlementID string, opts ...ListOption) ([]Subscriber, error) {
+	return c.ListEntitlementSubscribersContext(context.Background(), appID, entitlementID, opts...)
+}
+
+func (c *Client) ListEntitlementSubscribersContext(ctx context.Context, appID, entitlementID string, opts ...ListOption) ([]Subscriber, error) {
+	var result []Subscriber
+	err := c.request(ctx, "GET", fmt.Sprintf("/v1/apps/%s/entitlements/%s/subscribers", appID, entitlementID), nil, nil, &result, opts)
+	return result, err
+}
+
+// ListEntitlementChanges lists the entitlement grants, revocations and
+// expirations that happened in an app between from and to.
+func (c *Client) ListEntitlementChanges(appID string, from, to time.Time, opts ...ListOption) ([]EntitlementChange, error) {
+	return c.ListEntitlementChangesContext(context.Background(), appID, from, to, opts...)
+}
+
+func (c *Client) ListEntitlementChangesContext(ctx context.Context, appID string, from, to time.Time, opts ...ListOption) ([]EntitlementChange, error) {
+	q := url.Values{}
+	setDateRange(q, from, to)
+	var result []EntitlementChange
+	err := c.request(ctx, "GET", fmt.Sprintf("/v1/apps/%s/entitlements/changes", appID), nil, q, &result, opts)
+	return result, err
+}
+
+// -- receipts --
+
+// SubmitReceipt verifies a receipt with its store and records the resulting
+// transaction against the app's product productID. A receipt missing a
+// detail its store needs is rejected before any request is made.
+//
+// A submission retried after a timeout may record a duplicate transaction
+// unless it carries an idempotency key: generate a UUID for each logical
+// submission, pass it with WithIdempotencyKey, and pass the same key when
+// resubmitting. With WithRetry, keyed submissions are retried with the same
+// key.
+func (c *Client) SubmitReceipt(appID, appUserID, productID string, receipt Receipt, opts ...CallOption) (*Transaction, error) {
+	return c.SubmitReceiptContext(context.Background(), appID, appUserID, productID, receipt, opts...)
+}
+
+func (c *Client) SubmitReceiptContext(ctx context.Context, appID, appUserID, productID string, receipt Receipt, opts ...CallOption) (*Transaction, error) {
+	opts = c.audited("SubmitReceipt", map[string]string{"app_id": appID, "app_user_id": appUserID, "product_id": productID}, c.receiptOptions(opts))
+	body, err := receiptBody(appID, receipt)
+	if err != nil {
+		return nil, err
+	}
+	body["app_user_id"] = appUserID
+	body["product_id"] = productID
+	var result Transaction
+	err = c.request(ctx, "POST", "/v1/receipts", body, nil, &result, opts)
+	return &result, err
+}
+
+// receiptOptions applies the client's WithDefaultCreateSubscriberIfMissing
+// ahead of opts, so a per-call setting wins.
+func (c *Client) receiptOptions(opts []CallOption) []CallOption {
+	if c.createSubscriber == nil {
+		return opts
+	}
+	return append([]CallOption{WithCreateSubscriberIfMissing(*c.createSubscriber)}, opts...)
+}
+
+// RecordPurchase submits a receipt for the app's product with the given
+// store product ID and returns the subscriber's refreshed state, so callers
+// need not look up the OpenCat product ID themselves.
+func (c *Client) RecordPurchase(appID, appUserID, storeProductID string, receipt Receipt, opts ...CallOption) (*SubscriberInfo, error) {
+	return c.RecordPurchaseContext(context.Background(), appID, appUserID, storeProductID, receipt, opts...)
+}
+
+func (c *Client) RecordPurchaseContext(ctx context.Context, appID, appUserID, storeProductID string, receipt Receipt, opts ...CallOption) (*SubscriberInfo, error) {
+	product, err := c.findProduct(ctx, appID, storeProductID, newCallOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	if product == nil {
+		return nil, fmt.Errorf("opencat: no product with store product ID %q", storeProductID)
+	}
+	if _, err := c.SubmitReceiptContext(ctx, appID, appUserID, product.ID, receipt, opts...); err != nil {
+		return nil, err
+	}
+	return c.GetSubscriberContext(ctx, appUserID, opts...)
+}
+
+// SubmitReceipts verifies a batch of receipts in one request. A receipt that
+// fails verification does not fail the batch; check each item's Error.
+func (c *Client) SubmitReceipts(batch []ReceiptSubmission, opts ...CallOption) (*ReceiptBatchResult, error) {
+	return c.SubmitReceiptsContext(context.Background(), batch, opts...)
+}
+
+func (c *Client) SubmitReceiptsContext(ctx context.Context, batch []ReceiptSubmission, opts ...CallOption) (*ReceiptBatchResult, error) {
+	opts = c.audited("SubmitReceipts", nil, c.receiptOptions(opts))
+	receipts := make([]map[string]string, len(batch))
+	for i, sub := range batch {
+		body, err := receiptBody(sub.AppID, sub.Receipt)
+		if err != nil {
+			return nil, fmt.Errorf("opencat: receipt %d: %w", i, err)
+		}
+		body["app_user_id"] = sub.AppUserID
+		body["product_id"] = sub.ProductID
+		receipts[i] = body
+	}
+	var result ReceiptBatchResult
+	err := c.request(ctx, "POST", "/v1/receipts/batch", map[string]any{"receipts": receipts}, nil, &result, opts)
+	return &result, err
+}
+
+// ErrInvalidReceipt is returned, wrapping the server's *Error, when the store
+// rejects a receipt as invalid.
+var ErrInvalidReceipt = errors.New("opencat: invalid receipt")
+
+// ReceiptGrantsEntitlement verifies a receipt with its store and reports
+// whether it would grant the entitlement named entitlementName. Nothing is
+// persisted.
+func (c *Client) ReceiptGrantsEntitlement(appID string, receipt Receipt, entitlementName string, opts ...CallOption) (bool, error) {
+	return c.ReceiptGrantsEntitlementContext(context.Background(), appID, receipt, entitlementName, opts...)
+}
+
+func (c *Client) ReceiptGrantsEntitlementContext(ctx context.Context, appID string, receipt Receipt, entitlementName string, opts ...CallOption) (bool, error) {
+	body, err := receiptBody(appID, receipt)
+	if err != nil {
+		return false, err
+	}
+	var result struct {
+		Entitlements []string `json:"entitlements"`
+	}
+	err = c.request(ctx, "POST", "/v1/receipts/entitlements", body, nil, &result, opts)
+	if hasStatus(err, http.StatusUnprocessableEntity) {
+		return false, fmt.Errorf("%w: %w", ErrInvalidReceipt, err)
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, name := range result.Entitlements {
+		if name == entitlementName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SubmitGoogleReceipt is SubmitReceipt with a GoogleReceipt. playProductID is
+// the product ID in Google Play; productID is the OpenCat product it is
+// recorded against.
+func (c *Client) SubmitGoogleReceipt(appID, appUserID, packageName, playProductID, purchaseToken, productID string, opts ...CallOption) (*Transaction, error) {
+	return c.SubmitGoogleReceiptContext(context.Background(), appID, appUserID, packageName, playProductID, purchaseToken, productID, opts...)
+}
+
+func (c *Client) SubmitGoogleReceiptContext(ctx context.Context, appID, appUserID, packageName, playProductID, purchaseToken, productID string, opts ...CallOption) (*Transaction, error) {
+	return c.SubmitReceiptContext(ctx, appID, appUserID, productID, GoogleReceipt(packageName, purchaseToken, playProductID), opts...)
+}
+
+// SubmitAmazonReceipt is SubmitReceipt with an AmazonReceipt.
+func (c *Client) SubmitAmazonReceipt(appID, appUserID, userID, receiptID, productID string, opts ...CallOption) (*Transaction, error) {
+	return c.SubmitAmazonReceiptContext(context.Background(), appID, appUserID, userID, receiptID, productID, opts...)
+}
+
+func (c *Client) SubmitAmazonReceiptContext(ctx context.Context, appID, appUserID, userID, receiptID, productID string, opts ...CallOption) (*Transaction, error) {
+	return c.SubmitReceiptContext(ctx, appID, appUserID, productID, AmazonReceipt(userID, receiptID), opts...)
+}
+
+// -- transactions --
+
+func (c *Client) ListRefunds(appID string, from, to time.Time, opts ...ListOption) ([]Transaction, error) {
+	return c.ListRefundsContext(context.Background(), appID, from, to, opts...)
+}
+
+func (c *Client) ListRefundsContext(ctx context.Context, appID string, from, to time.Time, opts ...ListOption) ([]Transaction, error) {
+	return c.listTransactionsByStatus(ctx, appID, "refunded", from, to, opts)
+}
+
+func (c *Client) ListChargebacks(appID string, from, to time.Time, opts ...ListOption) ([]Transaction, error) {
+	return c.ListChargebacksContext(context.Background(), appID, from, to, opts...)
+}
+
+func (c *Client) ListChargebacksContext(ctx context.Context, appID string, from, to time.Time, opts ...ListOption) ([]Transaction, error) {
+	return c.listTransactionsByStatus(ctx, appID, "chargeback", from, to, opts)
+}
+
+func (c *Client) ListProductTransactions(appID, productID string, from, to time.Time, opts ...ListOption) ([]Transaction, error) {
+	return c.ListProductTransactionsContext(context.Background(), appID, productID, from, to, opts...)
+}
+
+func (c *Client) ListProductTransactionsContext(ctx context.Context, appID, productID string, from, to time.Time, opts ...ListOption) ([]Transaction, error) {
+	return c.listAppTransactions(ctx, appID, url.Values{"product_id": {productID}}, from, to, opts)
+}
+
+func (c *Client) listTransactionsByStatus(ctx context.Context, appID, status string, from, to time.Time, opts []ListOption) ([]Transaction, error) {
+	return c.listAppTransactions(ctx, appID, url.Values{"status": {status}}, from, to, opts)
+}
+
+func (c *Client) listAppTransactions(ctx context.Context, appID string, q url.Values, from, to time.Time, opts []ListOption) ([]Transaction, error) {
+	setDateRange(q, from, to)
+	var result []Transaction
+	err := c.request(ctx, "GET", fmt.Sprintf("/v1/apps/%s/transactions", appID), nil, q, &result, opts)
+	return result, err
+}
+
+// ListTransactions lists the transactions of a subscriber, newest first,
+// without their entitlements. WithTransactionStatus and WithStore filter
+// the list; WithLimit and WithCursor walk long histories page by page.
+func (c *Client) ListTransactions(appUserID string, opts ...ListOption) ([]Transaction, error) {
+	return c.ListTransactionsContext(context.Background(), appUserID, opts...)
+}
+
+func (c *Client) ListTransactionsContext(ctx context.Context, appUserID string, opts ...ListOption) ([]Transaction, error) {
+	var result []Transaction
+	err := c.request(ctx, "GET", "/v1/subscribers/"+url.PathEscape(appUserID)+"/transactions", nil, nil, &result, opts)
+	return result, err
+}
+
+// GetTransaction fetches a single transaction by its OpenCat ID. An unknown
+// transactionID is an error for which IsNotFound reports true.
+func (c *Client) GetTransaction(transactionID string, opts ...CallOption) (*Transaction, error) {
+	return c.GetTransactionContext(context.Background(), transactionID, opts...)
+}
+
+func (c *Client) GetTransactionContext(ctx context.Context, transactionID string, opts ...CallOption) (*Transaction, error) {
+	var result Transaction
+	if err := c.request(ctx, "GET", "/v1/transactions/"+url.PathEscape(transactionID), nil, nil, &result, opts); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ExportTransactions passes every transaction of an app to fn in a stable
+// order, starting after cursor ("" to start from the beginning). After each
+// page, checkpoint (if non-nil) receives the cursor to resume from. The
+// returned cursor always points just after the last transaction fn accepted,
+// so passing it back after a failure continues with no gaps or repeats.
+// WithLimit sets the page size.
+func (c *Client) ExportTransactions(appID, cursor string, fn func(Transaction) error, checkpoint func(cursor string), opts ...ListOption) (string, error) {
+	return c.ExportTransactionsContext(context.Background(), appID, cursor, fn, checkpoint, opts...)
+}
+
+func (c *Client) ExportTransactionsContext(ctx context.Context, appID, cursor string, fn func(Transaction) error, checkpoint func(cursor string), opts ...ListOption) (string, error) {
+	path := fmt.Sprintf("/v1/apps/%s/transactions/export", appID)
+	for {
+		q := url.Values{}
+		if cursor != "" {
+			q.Set("after", cursor)
+		}
+		var page struct {
+			Transactions []Transaction `json:"transactions"`
+			HasMore      bool          `json:"has_more"`
+		}
+		if err := c.request(ctx, "GET", path, nil, q, &page, opts); err != nil {
+			return cursor, err
+		}
+		for _, tx := range page.Transactions {
+			if err := fn(tx); err != nil {
+				return cursor, err
+			}
+			cursor = tx.ID
+		}
+		if checkpoint != nil && len(page.Transactions) > 0 {
+			checkpoint(cursor)
+		}
+		if !page.HasMore || len(page.Transactions) == 0 {
+			return cursor, nil
+		}
+	}
+}
+
+// ListStoreTransactionIDs returns the store transaction IDs the server holds
+// for an app and store, following pages until all are fetched. WithLimit
+// sets the page size.
+func (c *Client) ListStoreTransactionIDs(appID, store string, from, to time.Time, opts ...ListOption) ([]string, error) {
+	return c.ListStoreTransactionIDsContext(context.Background(), appID, store, from, to, opts...)
+}
+
+func (c *Client) ListStoreTransactionIDsContext(ctx context.Context, appID, store string, from, to time.Time, opts ...ListOption) ([]string, error) {
+	if !Store(store).Valid() {
+		return nil, fmt.Errorf("opencat: unknown store %q", store)
+	}
+	var ids []string
+	cursor := ""
+	for {
+		q := url.Values{"store": {store}}
+		setDateRange(q, from, to)
+		if cursor != "" {
+			q.Set("cursor", cursor)
+		}
+		var page struct {
+			IDs        []string `json:"ids"`
+			NextCursor string   `json:"next_cursor"`
+		}
+		if err := c.request(ctx, "GET", fmt.Sprintf("/v1/apps/%s/transactions/store-ids", appID), nil, q, &page, opts); err != nil {
+			return ids, err
+		}
+		ids = append(ids, page.IDs...)
+		if page.NextCursor == "" {
+			return ids, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// ErrInconsistentDeletion is returned, wrapping the server's *Error, when the
+// server refuses to delete a transaction because the subscriber's remaining
+// history would no longer be consistent.
+var ErrInconsistentDeletion = errors.New("opencat: deletion would leave an inconsistent state")
+
+// DeleteTransaction removes a transaction and recomputes the entitlements of
+// its subscriber. It needs an API key allowed to delete transactions.
+func (c *Client) DeleteTransaction(transactionID string, opts ...CallOption) error {
+	return c.DeleteTransactionContext(context.Background(), transactionID, opts...)
+}
+
+func (c *Client) DeleteTransactionContext(ctx context.Context, transactionID string, opts ...CallOption) error {
+	opts = c.audited("DeleteTransaction", map[string]string{"transaction_id": transactionID}, opts)
+	err := c.request(ctx, "DELETE", "/v1/transactions/"+url.PathEscape(transactionID), nil, nil, nil, opts)
+	if hasStatus(err, http.StatusConflict) {
+		return fmt.Errorf("%w: %w", ErrInconsistentDeletion, err)
+	}
+	return err
+}
+
+// GetTransactionStatusHistory lists the status transitions of a
+// transaction, oldest first.
+func (c *Client) GetTransactionStatusHistory(transactionID string, opts ...CallOption) ([]StatusChange, error) {
+	return c.GetTransactionStatusHistoryContext(context.Background(), transactionID, opts...)
+}
+
+func (c *Client) GetTransactionStatusHistoryContext(ctx context.Context, transactionID string, opts ...CallOption) ([]StatusChange, error) {
+	var result []StatusChange
+	err := c.request(ctx, "GET", "/v1/transactions/"+url.PathEscape(transactionID)+"/status-history", nil, nil, &result, opts)
+	return result, err
+}
+
+// HasTransaction reports whether the server has already ingested the given
+// store transaction.
+func (c *Client) HasTransaction(store, storeTransactionID string, opts ...CallOption) (bool, error) {
+	return c.HasTransactionContext(context.Background(), store, storeTransactionID, opts...)
+}
+
+func (c *Client) HasTransactionContext(ctx context.Context, store, storeTransactionID string, opts ...CallOption) (bool, error) {
+	_, ok, err := c.FindTransactionIDContext(ctx, store, storeTransactionID, opts...)
+	return ok, err
+}
+
+// FindTransactionID is HasTransaction that also returns the OpenCat ID of the
+// transaction when it exists.
+func (c *Client) FindTransactionID(store, storeTransactionID string, opts ...CallOption) (string, bool, error) {
+	return c.FindTransactionIDContext(context.Background(), store, storeTransactionID, opts...)
+}
+
+func (c *Client) FindTransactionIDContext(ctx context.Context, store, storeTransactionID string, opts ...CallOption) (string, bool, error) {
+	q := url.Values{}
+	q.Set("store", store)
+	q.Set("store_transaction_id", storeTransactionID)
+	var result struct {
+		ID string `json:"id"`
+	}
+	err := c.request(ctx, "GET", "/v1/transactions/lookup", nil, q, &result, opts)
+	if hasStatus(err, http.StatusNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return result.ID, true, nil
+}
+
+// -- metrics --
+
+func (c *Client) GetMRRMovement(appID string, from, to time.Time, opts ...CallOption) (*MRRMovement, error) {
+	return c.GetMRRMovementContext(context.Background(), appID, from, to, opts...)
+}
+
+func (c *Client) GetMRRMovementContext(ctx context.Context, appID string, from, to time.Time, opts ...CallOption) (*MRRMovement, error) {
+	q := url.Values{}
+	setDateRange(q, from, to)
+	var result MRRMovement
+	err := c.request(ctx, "GET", fmt.Sprintf("/v1/apps/%s/metrics/mrr-movement", appID), nil, q, &result, opts)
+	return &result, err
+}
+
+// GetTrialConversion reports, per product, how many trials started between
+// from and to and how many of those converted to a paid subscription.
+func (c *Client) GetTrialConversion(appID string, from, to time.Time, opts ...CallOption) ([]ConversionStat, error) {
+	return c.GetTrialConversionContext(context.Background(), appID, from, to, opts...)
+}
+
+func (c *Client) GetTrialConversionContext(ctx context.Context, appID string, from, to time.Time, opts ...CallOption) ([]ConversionStat, error) {
+	q := url.Values{}
+	setDateRange(q, from, to)
+	var result []ConversionStat
+	err := c.request(ctx, "GET", fmt.Sprintf("/v1/apps/%s/metrics/trial-conversion", appID), nil, q, &result, opts)
+	return result, err
+}
+
+// GetProductRevenue returns a product's revenue between from and to, one
+// point per granularity bucket.
+func (c *Client) GetProductRevenue(appID, productID string, from, to time.Time, granularity Granularity, opts ...CallOption) ([]RevenuePoint, error) {
+	return c.GetProductRevenueContext(context.Background(), appID, productID, from, to, granularity, opts...)
+}
+
+func (c *Client) GetProductRevenueContext(ctx context.Context, appID, productID string, from, to time.Time, granularity Granularity, opts ...CallOption) ([]RevenuePoint, error) {
+	q := url.Values{"granularity": {string(granularity)}}
+	setDateRange(q, from, to)
+	var result []RevenuePoint
+	err := c.request(ctx, "GET", fmt.Sprintf("/v1/apps/%s/products/%s/revenue", appID, productID), nil, q, &result, opts)
+	return result, err
+}
+
+// GetEntitlementDistribution counts active subscribers per entitlement name.
+// Subscribers with no active entitlement are counted under "none"; those with
+// several are counted once under each.
+func (c *Client) GetEntitlementDistribution(appID string, opts ...CallOption) (map[string]int, error) {
+	return c.GetEntitlementDistributionContext(context.Background(), appID, opts...)
+}
+
+func (c *Client) GetEntitlementDistributionContext(ctx context.Context, appID string, opts ...CallOption) (map[string]int, error) {
+	var result map[string]int
+	err := c.request(ctx, "GET", fmt.Sprintf("/v1/apps/%s/metrics/entitlement-distribution", appID), nil, nil, &result, opts)
+	return result, err
+}
+
+// GetSubscribersByStore counts active subscribers per store. Each subscriber
+// is counted once per store they hold an active subscription in, so a user
+// subscribed on both apple and google appears under both, but two active
+// apple subscriptions count once.
+func (c *Client) GetSubscribersByStore(appID string, opts ...CallOption) (map[string]int, error) {
+	return c.GetSubscribersByStoreContext(context.Background(), appID, opts...)
+}
+
+func (c *Client) GetSubscribersByStoreContext(ctx context.Context, appID string, opts ...CallOption) (map[string]int, error) {
+	var result map[string]int
+	err := c.request(ctx, "GET", fmt.Sprintf("/v1/apps/%s/metrics/subscribers-by-store", appID), nil, nil, &result, opts)
+	return result, err
+}
+
+// -- webhooks --
+
+func (c *Client) CreateWebhook(appID, webhookURL string, opts ...CallOption) (*WebhookEndpoint, error) {
+	return c.CreateWebhookContext(context.Background(), appID, webhookURL, opts...)
+}
+
+func (c *Client) CreateWebhookContext(ctx context.Context, appID, webhookURL string, opts ...CallOption) (*WebhookEndpoint, error) {
+	opts = c.audited("CreateWebhook", map[string]string{"app_id": appID}, opts)
+	var result WebhookEndpoint
+	err := c.request(ctx, "POST", "/v1/webhooks", map[string]string{
+		"app_id": appID, "url": webhookURL,
+	}, nil, &result, opts)
+	return &result, err
+}
+
+func (c *Client) UpdateWebhook(webhookID string, update WebhookUpdate, opts ...CallOption) (*WebhookEndpoint, error) {
+	return c.UpdateWebhookContext(context.Background(), webhookID, update, opts...)
+}
+
+func (c *Client) UpdateWebhookContext(ctx context.Context, webhookID string, update WebhookUpdate, opts ...CallOption) (*WebhookEndpoint, error) {
+	opts = c.audited("UpdateWebhook", map[string]string{"webhook_id": webhookID}, opts)
+	var result WebhookEndpoint
+	err := c.request(ctx, "PATCH", "/v1/webhooks/"+url.PathEscape(webhookID), update, nil, &result, opts)
+	return &result, err
+}
+
+// DeleteWebhook deletes a webhook endpoint; no further deliveries are made to
+// it. An endpoint that does not exist is an error for which IsNotFound
+// reports true.
+func (c *Client) DeleteWebhook(webhookID string, opts ...CallOption) error {
+	return c.DeleteWebhookContext(context.Background(), webhookID, opts...)
+}
+
+func (c *Client) DeleteWebhookContext(ctx context.Context, webhookID string, opts ...CallOption) error {
+	opts = c.audited("DeleteWebhook", map[string]string{"webhook_id": webhookID}, opts)
+	return c.request(ctx, "DELETE", "/v1/webhooks/"+url.PathEscape(webhookID), nil, nil, nil, opts)
+}
+
+// EnableWebhook re-activates an endpoint and resets its failure count.
+func (c *Client) EnableWebhook(webhookID string, opts ...CallOption) (*WebhookEndpoint, error) {
+	return c.EnableWebhookContext(context.Background(), webhookID, opts...)
+}
+
+func (c *Client) EnableWebhookContext(ctx context.Context, webhookID string, opts ...CallOption) (*WebhookEndpoint, error) {
+	opts = c.audited("EnableWebhook", map[string]string{"webhook_id": webhookID}, opts)
+	var result WebhookEndpoint
+	err := c.request(ctx, "POST", "/v1/webhooks/"+url.PathEscape(webhookID)+"/enable", nil, nil, &result, opts)
+	return &result, err
+}
+
+// ValidateWebhookURL asks the server to send a challenge to webhookURL and
+// report whether it answered with a 2xx. Nothing is saved.
+func (c *Client) ValidateWebhookURL(webhookURL string, opts ...CallOption) (*WebhookValidation, error) {
+	return c.ValidateWebhookURLContext(context.Background(), webhookURL, opts...)
+}
+
+func (c *Client) ValidateWebhookURLContext(ctx context.Context, webhookURL string, opts ...CallOption) (*WebhookValidation, error) {
+	if u, err := url.Parse(webhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("opencat: invalid webhook URL %q", webhookURL)
+	}
+	var result WebhookValidation
+	err := c.request(ctx, "POST", "/v1/webhooks/validate", map[string]string{"url": webhookURL}, nil, &result, opts)
+	return &result, err
+}
+
+func (c *Client) GetWebhookHealth(webhookID string, opts ...CallOption) (*WebhookHealth, error) {
+	return c.GetWebhookHealthContext(context.Background(), webhookID, opts...)
+}
+
+func (c *Client) GetWebhookHealthContext(ctx context.Context, webhookID string, opts ...CallOption) (*WebhookHealth, error) {
+	var result WebhookHealth
+	err := c.request(ctx, "GET", "/v1/webhooks/"+url.PathEscape(webhookID)+"/health", nil, nil, &result, opts)
+	return &result, err
+}
+
+// ListWebhookDeliveries lists the delivery attempts of a webhook endpoint,
+// newest first. Filter with WithDeliveryStatus and WithDateRange; page with
+// WithLimit and WithCursor.
+func (c *Client) ListWebhookDeliveries(webhookID string, opts ...ListOption) ([]WebhookDelivery, error) {
+	return c.ListWebhookDeliveriesContext(context.Background(), webhookID, opts...)
+}
+
+func (c *Client) ListWebhookDeliveriesContext(ctx context.Context, webhookID string, opts ...ListOption) ([]WebhookDelivery, error) {
+	var result []WebhookDelivery
+	err := c.request(ctx, "GET", "/v1/webhooks/"+url.PathEscape(webhookID)+"/deliveries", nil, nil, &result, opts)
+	return result, err
+}
+
+// ListFailedWebhookDeliveries lists deliveries that failed, including those
+// moved to the dead letter queue after their last retry.
+func (c *Client) ListFailedWebhookDeliveries(webhookID string, opts ...ListOption) ([]WebhookDelivery, error) {
+	return c.ListFailedWebhookDeliveriesContext(context.Background(), webhookID, opts...)
+}
+
+func (c *Client) ListFailedWebhookDeliveriesContext(ctx context.Context, webhookID string, opts ...ListOption) ([]WebhookDelivery, error) {
+	opts = append([]ListOption{WithDeliveryStatus(DeliveryFailed, DeliveryDeadLetter)}, opts...)
+	return c.ListWebhookDeliveriesContext(ctx, webhookID, opts...)
+}
+
+func (c *Client) ListWebhooks(opts ...CallOption) ([]WebhookEndpoint, error) {
+	return c.ListWebhooksContext(context.Background(), opts...)
+}
+
+func (c *Client) ListWebhooksContext(ctx context.Context, opts ...CallOption) ([]WebhookEndpoint, error) {
+	reveal := newCallOptions(opts).reveal
+	q := url.Values{}
+	q.Set("include_secrets", strconv.FormatBool(reveal))
+	var result []WebhookEndpoint
+	err := c.request(ctx, "GET", "/v1/webhooks", nil, q, &result, opts)
+	if !reveal {
+		for i := range result {
+			result[i].Secret = ""
+		}
+	}
+	return result, err
+}
+
+func (c *Client) GetWebhookSecret(webhookID string, opts ...CallOption) (Secret, error) {
+	return c.GetWebhookSecretContext(context.Background(), webhookID, opts...)
+}
+
+func (c *Client) GetWebhookSecretContext(ctx context.Context, webhookID string, opts ...CallOption) (Secret, error) {
+	var result struct {
+		Secret Secret `json:"secret"`
+	}
+	err := c.request(ctx, "GET", "/v1/webhooks/"+url.PathEscape(webhookID)+"/secret", nil, nil, &result, opts)
+	return result.Secret, err
+}
+
+// -- events --
+
+// ListEvents returns events created after cursor, which is the CreatedAt of
+// an event exactly as the server returned it. The server orders them
+// oldest first when a cursor is given and newest first otherwise; WithOrder
+// overrides this. Paging forward by passing the last event back as the cursor
+// only works in ascending order: in descending order a page holds the newest
+// events after the cursor, so use it for "latest first" views rather than for
+// walking the feed. WithEventType and WithSubscriberID narrow the events
+// returned.
+func (c *Client) ListEvents(cursor string, opts ...ListOption) ([]Event, error) {
+	return c.ListEventsContext(context.Background(), cursor, opts...)
+}
+
+func (c *Client) ListEventsContext(ctx context.Context, cursor string, opts ...ListOption) ([]Event, error) {
+	q := url.Values{}
+	if cursor != "" {
+		q.Set("since", cursor)
+	}
+	var result []Event
+	err := c.request(ctx, "GET", "/v1/events", nil, q, &result, opts)
+	return result, err
+}
+
+// LatestEventCursor returns the CreatedAt of the newest event, to pass to
+// ListEvents, EventsIterator or DecodedEvents to receive only events created
+// from now on. It returns "" if there are no events yet. The server pages by
+// CreatedAt alone, so an event stored after this call with exactly the
+// newest CreatedAt is skipped, as when resuming from EventIterator.Cursor.
+// WithOrder and WithCursor are ignored.
+func (c *Client) LatestEventCursor(opts ...CallOption) (string, error) {
+	return c.LatestEventCursorContext(context.Background(), opts...)
+}
+
+func (c *Client) LatestEventCursorContext(ctx context.Context, opts ...CallOption) (string, error) {
+	// Without a since cursor the server returns the newest events first.
+	opts = append(eventFeedOptions(opts), WithLimit(1))
+	events, err := c.ListEventsContext(ctx, "", opts...)
+	if err != nil || len(events) == 0 {
+		return "", err
+	}
+	return events[0].CreatedAt, nil
+}
+
+// GetEvent fetches a single event with its full payload, for events listed
+// with WithFields.
+func (c *Client) GetEvent(eventID string, opts ...CallOption) (*Event, error) {
+	return c.GetEventContext(context.Background(), eventID, opts...)
+}
+
+func (c *Client) GetEventContext(ctx context.Context, eventID string, opts ...CallOption) (*Event, error) {
+	var result Event
+	err := c.request(ctx, "GET", "/v1/events/"+url.PathEscape(eventID), nil, nil, &result, opts)
+	return &result, err
+}
+
+// AckEvents records that the given events have been processed, so the server
+// can advance the consumer's position past them. Positions are kept per
+// consumer: calls without WithConsumer share the API key's default consumer.
+// Consumers using the same name share one position, so an event acknowledged
+// by one of them counts as processed for all; give each consumer its own name
+// if every one of them must see every event.
+func (c *Client) AckEvents(eventIDs []string, opts ...CallOption) error {
+	return c.AckEventsContext(context.Background(), eventIDs, opts...)
+}
+
+func (c *Client) AckEventsContext(ctx context.Context, eventIDs []string, opts ...CallOption) error {
+	opts = c.audited("AckEvents", nil, opts)
+	return c.request(ctx, "POST", "/v1/events/ack", map[string]any{"event_ids": eventIDs}, nil, nil, opts)
+}
+
+// AckThrough acknowledges every event up to and including cursor. It has the
+// same per-consumer semantics as AckEvents.
+func (c *Client) AckThrough(cursor string, opts ...CallOption) error {
+	return c.AckThroughContext(context.Background(), cursor, opts...)
+}
+
+func (c *Client) AckThroughContext(ctx context.Context, cursor string, opts ...CallOption) error {
+	opts = c.audited("AckThrough", nil, opts)
+	return c.request(ctx, "POST", "/v1/events/ack", map[string]any{"through": cursor}, nil, nil, opts)
+}
@@ -1,10 +1,18 @@
 package opencat
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func setupServer(t *testing.T, handler http.HandlerFunc) (*Client, *httptest.Server) {
@@ -14,6 +22,36 @@ func setupServer(t *testing.T, handler http.HandlerFunc) (*Client, *httptest.Ser
 	return c, srv
 }
 
+func TestServerInfo(t *testing.T) {
+	apiVersion := "1.3"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		fmt.Fprintf(w, `{"status":"ok","version":"0.4.0","api_version":%q}`, apiVersion)
+	}))
+	defer srv.Close()
+
+	var warned *ServerInfo
+	c := NewClient(srv.URL, "test-key", WithIncompatibleServerHook(func(info *ServerInfo) { warned = info }))
+
+	info, err := c.ServerInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != "0.4.0" || warned != nil {
+		t.Fatalf("unexpected info %+v, warned %v", info, warned)
+	}
+
+	apiVersion = "2"
+	if _, err := c.ServerInfo(); err != nil {
+		t.Fatal(err)
+	}
+	if warned == nil || warned.APIVersion != "2" {
+		t.Fatalf("expected incompatible hook to fire, got %v", warned)
+	}
+}
+
 func TestCreateApp(t *testing.T) {
 	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" || r.URL.Path != "/v1/apps" {
@@ -53,6 +91,43 @@ func TestListApps(t *testing.T) {
 	}
 }
 
+func TestGetAppFacets(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/apps/app-1/facets" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"stores":["apple","stripe"],"product_types":["subscription"],"entitlement_names":["premium"]}`))
+	})
+	defer srv.Close()
+
+	facets, err := c.GetAppFacets("app-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(facets.Stores) != 2 || facets.Stores[1] != StoreStripe || facets.ProductTypes[0] != ProductSubscription || facets.EntitlementNames[0] != "premium" {
+		t.Fatalf("unexpected facets %+v", facets)
+	}
+}
+
+func TestGetAppConfig(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/apps/app-1/config" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"app":{"id":"app-1","name":"Demo"},"credentials":{"apple":true,"google":false},
+			"webhooks":[{"id":"wh1","url":"https://example.com/hook","active":true}],"entitlement_count":2,"product_count":3}`))
+	})
+	defer srv.Close()
+
+	cfg, err := c.GetAppConfig("app-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Credentials[StoreApple] || cfg.Credentials[StoreGoogle] || len(cfg.Webhooks) != 1 || cfg.ProductCount != 3 {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+}
+
 func TestGetSubscriber(t *testing.T) {
 	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(SubscriberInfo{
@@ -90,6 +165,81 @@ func TestCreateProduct(t *testing.T) {
 	}
 }
 
+func TestCreateProductDefaultType(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		got = append(got, body["product_type"].(string))
+		json.NewEncoder(w).Encode(Product{ID: "p1"})
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithDefaultProductType(ProductSubscription))
+	if _, err := c.CreateProduct("app-1", "com.example.pro", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateProduct("app-1", "com.example.coins", string(ProductConsumable), nil); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "subscription,consumable" {
+		t.Fatalf("unexpected product types %v", got)
+	}
+
+	if _, err := NewClient(srv.URL, "test-key").CreateProduct("app-1", "com.example.pro", "", nil); err == nil {
+		t.Fatal("expected error without a default product type")
+	}
+}
+
+func TestUpdateProductFieldMask(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" || r.URL.Path != "/v1/apps/app-1/products/p1" {
+			t.Fatalf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if len(body) != 2 || body["display_name"] != "Pro" || fmt.Sprint(body["update_mask"]) != "[display_name]" {
+			t.Fatalf("unexpected body %v", body)
+		}
+		json.NewEncoder(w).Encode(Product{ID: "p1"})
+	})
+	defer srv.Close()
+
+	name := "Pro"
+	update := ProductUpdate{DisplayName: &name, ProductType: ProductConsumable}
+	if _, err := c.UpdateProduct("app-1", "p1", update, []string{"display_name"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UpdateProduct("app-1", "p1", update, []string{"description"}); err == nil {
+		t.Fatal("expected error for masked field that is not set")
+	}
+	if _, err := c.UpdateProduct("app-1", "p1", update, []string{"price"}); err == nil {
+		t.Fatal("expected error for unknown field")
+	}
+	if _, err := c.UpdateProduct("app-1", "p1", update, nil); err == nil {
+		t.Fatal("expected error for empty mask")
+	}
+}
+
+func TestGetProductWithEntitlements(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/apps/app-1/products/p1" || r.URL.Query().Get("include") != "entitlements" {
+			t.Fatalf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"product":{"id":"p1","store_product_id":"com.example.pro","entitlement_ids":["e1"]},
+			"entitlements":[{"id":"e1","name":"pro","description":"All features"}]}`))
+	})
+	defer srv.Close()
+
+	p, ents, err := c.GetProductWithEntitlements("app-1", "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.EntitlementIDs) != 1 || len(ents) != 1 || ents[0].Name != "pro" {
+		t.Fatalf("unexpected product %+v with entitlements %+v", p, ents)
+	}
+}
+
 func TestCreateEntitlement(t *testing.T) {
 	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(Entitlement{ID: "e1", AppID: "app-1", Name: "pro", CreatedAt: "t"})
@@ -115,7 +265,7 @@ func TestSubmitReceipt(t *testing.T) {
 	})
 	defer srv.Close()
 
-	tx, err := c.SubmitReceipt("app-1", "user-1", "apple", "data", "p1")
+	tx, err := c.SubmitReceipt("app-1", "user-1", "p1", AppleReceipt("data"))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -170,7 +320,8 @@ func TestErrorHandling(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected error")
 	}
-	apiErr, ok := err.(*Error)
+	var apiErr *Error
+	ok := errors.As(err, &apiErr)
 	if !ok {
 		t.Fatal("expected *Error")
 	}
@@ -178,3 +329,1978 @@ func TestErrorHandling(t *testing.T) {
 		t.Fatalf("expected 401, got %d", apiErr.StatusCode)
 	}
 }
+
+func TestContextCancelAbortsRequest(t *testing.T) {
+	aborted := make(chan struct{})
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(aborted)
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := c.GetSubscriberContext(ctx, "user-1"); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Fatal("expected the in-flight request to be aborted")
+	}
+}
+
+func TestHTTPClientOptions(t *testing.T) {
+	var agents []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents = append(agents, r.Header.Get("User-Agent"))
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	if c := NewClient(srv.URL, "test-key"); c.httpClient.Timeout != 30*time.Second {
+		t.Fatalf("expected the default timeout, got %s", c.httpClient.Timeout)
+	}
+	if c := NewClient(srv.URL, "test-key", WithTimeout(5*time.Second)); c.httpClient.Timeout != 5*time.Second {
+		t.Fatalf("expected WithTimeout to apply, got %s", c.httpClient.Timeout)
+	}
+
+	custom := &http.Client{}
+	c := NewClient(srv.URL, "test-key", WithTimeout(5*time.Second), WithHTTPClient(custom), WithUserAgent("acme-billing/2.1"))
+	if _, err := c.ListApps(); err != nil {
+		t.Fatal(err)
+	}
+	if c.httpClient != custom || custom.Timeout != 0 {
+		t.Fatalf("expected the custom client to be used unchanged, timeout %s", custom.Timeout)
+	}
+	if agents[0] != "acme-billing/2.1" {
+		t.Fatalf("unexpected User-Agent %q", agents[0])
+	}
+}
+
+func TestStructuredErrors(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/subscribers/missing":
+			w.WriteHeader(404)
+			w.Write([]byte(`{"code":"subscriber_not_found","message":"no subscriber missing"}`))
+		default:
+			w.WriteHeader(429)
+			w.Write([]byte(`slow down`))
+		}
+	})
+	defer srv.Close()
+
+	_, err := c.GetSubscriber("missing")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Code != "subscriber_not_found" || apiErr.Message != "no subscriber missing" {
+		t.Fatalf("expected a structured error, got %#v", err)
+	}
+	if !IsNotFound(err) || IsRateLimited(err) {
+		t.Fatalf("expected only IsNotFound for %v", err)
+	}
+	if err.Error() != "HTTP 404: subscriber_not_found: no subscriber missing" {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+
+	_, err = c.ListApps()
+	if !errors.As(err, &apiErr) || apiErr.Code != "" || apiErr.Detail != "slow down" {
+		t.Fatalf("expected the raw body in Detail, got %#v", err)
+	}
+	if !IsRateLimited(err) || IsNotFound(err) {
+		t.Fatalf("expected only IsRateLimited for %v", err)
+	}
+}
+
+func TestServerAndClientErrors(t *testing.T) {
+	status := 503
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})
+	defer srv.Close()
+
+	_, err := c.ListApps()
+	var serverErr *ServerError
+	var clientErr *ClientError
+	if !errors.As(err, &serverErr) || errors.As(err, &clientErr) || serverErr.Err.StatusCode != 503 {
+		t.Fatalf("expected a ServerError, got %#v", err)
+	}
+
+	status = 404
+	_, err = c.ListApps()
+	if !errors.As(err, &clientErr) || errors.As(err, &serverErr) || !hasStatus(err, 404) {
+		t.Fatalf("expected a ClientError, got %#v", err)
+	}
+}
+
+func TestListRefunds(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/apps/app-1/transactions" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("status") != "refunded" || q.Get("from") != "2024-01-01T00:00:00Z" || q.Get("to") != "2024-02-01T00:00:00Z" {
+			t.Fatalf("unexpected query %s", r.URL.RawQuery)
+		}
+		if q.Get("limit") != "50" || q.Get("cursor") != "tx0" {
+			t.Fatalf("unexpected pagination %s", r.URL.RawQuery)
+		}
+		json.NewEncoder(w).Encode([]Transaction{{ID: "tx1", Status: "refunded"}})
+	})
+	defer srv.Close()
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	txs, err := c.ListRefunds("app-1", from, to, WithLimit(50), WithCursor("tx0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 1 || txs[0].Status != "refunded" {
+		t.Fatalf("unexpected transactions: %+v", txs)
+	}
+}
+
+func TestExportTransactionsResume(t *testing.T) {
+	all := []string{"tx1", "tx2", "tx3", "tx4", "tx5"}
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/apps/app-1/transactions/export" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		start := 0
+		for i, id := range all {
+			if id == r.URL.Query().Get("after") {
+				start = i + 1
+			}
+		}
+		end := start + 2
+		if end > len(all) {
+			end = len(all)
+		}
+		var page []Transaction
+		for _, id := range all[start:end] {
+			page = append(page, Transaction{ID: id})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"transactions": page, "has_more": end < len(all)})
+	})
+	defer srv.Close()
+
+	var got, checkpoints []string
+	crash := fmt.Errorf("crash")
+	cursor, err := c.ExportTransactions("app-1", "", func(tx Transaction) error {
+		if tx.ID == "tx4" {
+			return crash
+		}
+		got = append(got, tx.ID)
+		return nil
+	}, func(cursor string) { checkpoints = append(checkpoints, cursor) })
+	if err != crash || cursor != "tx3" {
+		t.Fatalf("expected crash at cursor tx3, got %q, %v", cursor, err)
+	}
+	if strings.Join(checkpoints, ",") != "tx2" {
+		t.Fatalf("unexpected checkpoints %v", checkpoints)
+	}
+
+	cursor, err = c.ExportTransactions("app-1", cursor, func(tx Transaction) error {
+		got = append(got, tx.ID)
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cursor != "tx5" || strings.Join(got, ",") != strings.Join(all, ",") {
+		t.Fatalf("expected all transactions once, got %v (cursor %q)", got, cursor)
+	}
+}
+
+func TestListStoreTransactionIDs(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/v1/apps/app-1/transactions/store-ids" || q.Get("store") != "apple" || q.Get("from") == "" {
+			t.Fatalf("unexpected request %s", r.URL)
+		}
+		switch q.Get("cursor") {
+		case "":
+			w.Write([]byte(`{"ids":["1000","1001"],"next_cursor":"c2"}`))
+		case "c2":
+			w.Write([]byte(`{"ids":["1002"],"next_cursor":""}`))
+		default:
+			t.Fatalf("unexpected cursor %q", q.Get("cursor"))
+		}
+	})
+	defer srv.Close()
+
+	ids, err := c.ListStoreTransactionIDs("app-1", "apple", time.Now().Add(-24*time.Hour), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(ids, ",") != "1000,1001,1002" {
+		t.Fatalf("unexpected ids %v", ids)
+	}
+}
+
+func TestGetAppAndProduct(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/apps/app-1":
+			w.Write([]byte(`{"id":"app-1","name":"Demo"}`))
+		case "/v1/apps/app-1/products/p1":
+			w.Write([]byte(`{"id":"p1","app_id":"app-1","store_product_id":"pro"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":"not_found","message":"no such resource"}`))
+		}
+	})
+	defer srv.Close()
+
+	app, err := c.GetApp("app-1")
+	if err != nil || app.Name != "Demo" {
+		t.Fatalf("unexpected app %+v, %v", app, err)
+	}
+	product, err := c.GetProduct("app-1", "p1")
+	if err != nil || product.StoreProductID != "pro" {
+		t.Fatalf("unexpected product %+v, %v", product, err)
+	}
+	if app, err := c.GetApp("missing"); app != nil || !IsNotFound(err) {
+		t.Fatalf("expected a not found error, got %+v, %v", app, err)
+	}
+	if product, err := c.GetProduct("app-1", "missing"); product != nil || !IsNotFound(err) {
+		t.Fatalf("expected a not found error, got %+v, %v", product, err)
+	}
+}
+
+func TestDeleteResources(t *testing.T) {
+	var got []string
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" {
+			t.Fatalf("unexpected method %s", r.Method)
+		}
+		got = append(got, r.URL.Path)
+		if r.URL.Path == "/v1/webhooks/gone" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer srv.Close()
+
+	if err := c.DeleteApp("app-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteProduct("app-1", "p1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteWebhook("wh-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteWebhook("gone"); !IsNotFound(err) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+	want := "/v1/apps/app-1 /v1/apps/app-1/products/p1 /v1/webhooks/wh-1 /v1/webhooks/gone"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("unexpected paths %v", got)
+	}
+}
+
+func TestDeleteTransaction(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" {
+			t.Fatalf("unexpected method %s", r.Method)
+		}
+		switch r.URL.Path {
+		case "/v1/transactions/tx-test":
+			w.WriteHeader(204)
+		case "/v1/transactions/tx-original":
+			w.WriteHeader(409)
+			w.Write([]byte("transaction has renewals"))
+		default:
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+	})
+	defer srv.Close()
+
+	if err := c.DeleteTransaction("tx-test"); err != nil {
+		t.Fatal(err)
+	}
+	err := c.DeleteTransaction("tx-original")
+	var apiErr *Error
+	if !errors.Is(err, ErrInconsistentDeletion) || !errors.As(err, &apiErr) || apiErr.Detail != "transaction has renewals" {
+		t.Fatalf("expected inconsistent deletion error, got %v", err)
+	}
+}
+
+func TestListTransactions(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/subscribers/user 1/transactions" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("status") != "active,refunded" || q.Get("store") != "apple,stripe" {
+			t.Fatalf("unexpected filters %s", r.URL.RawQuery)
+		}
+		if q.Get("limit") != "2" || q.Get("cursor") != "tx0" {
+			t.Fatalf("unexpected pagination %s", r.URL.RawQuery)
+		}
+		json.NewEncoder(w).Encode([]Transaction{{ID: "tx1", Status: "active"}, {ID: "tx2", Status: "refunded"}})
+	})
+	defer srv.Close()
+
+	txs, err := c.ListTransactions("user 1",
+		WithTransactionStatus("active", "refunded"), WithStore(StoreApple, StoreStripe),
+		WithLimit(2), WithCursor("tx0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 2 || txs[1].ID != "tx2" {
+		t.Fatalf("unexpected transactions %+v", txs)
+	}
+}
+
+func TestGetTransaction(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Fatalf("unexpected method %s", r.Method)
+		}
+		switch r.URL.Path {
+		case "/v1/transactions/tx1":
+			w.Write([]byte(`{"id":"tx1","product_id":"pro_monthly","store":"google","status":"active"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer srv.Close()
+
+	tx, err := c.GetTransaction("tx1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.ProductID != "pro_monthly" || tx.Store != StoreGoogle {
+		t.Fatalf("unexpected transaction %+v", tx)
+	}
+	if tx, err := c.GetTransaction("missing"); !IsNotFound(err) || tx != nil {
+		t.Fatalf("expected not found, got %+v, %v", tx, err)
+	}
+}
+
+func TestGetTransactionStatusHistory(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/transactions/tx1/status-history" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte(`[
+			{"from":"active","to":"billing_retry","reason":"billing_failure","changed_at":"2024-03-01T00:00:00Z"},
+			{"from":"billing_retry","to":"expired","reason":"billing_failure","changed_at":"2024-03-17T00:00:00Z"}
+		]`))
+	})
+	defer srv.Close()
+
+	changes, err := c.GetTransactionStatusHistory("tx1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := changes[len(changes)-1]
+	if len(changes) != 2 || last.To != "expired" || last.Reason == nil || !last.Reason.Involuntary() {
+		t.Fatalf("unexpected history %+v", changes)
+	}
+}
+
+func TestListChargebacks(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("status") != "chargeback" {
+			t.Fatalf("unexpected query %s", r.URL.RawQuery)
+		}
+		json.NewEncoder(w).Encode([]Transaction{})
+	})
+	defer srv.Close()
+
+	if _, err := c.ListChargebacks("app-1", time.Time{}, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCallHeadersOverrideClientHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Canary") != "true" || r.Header.Get("X-Team") != "billing" {
+			t.Fatalf("unexpected headers %v", r.Header)
+		}
+		json.NewEncoder(w).Encode([]App{})
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithHeaders(http.Header{"X-Canary": {"false"}, "X-Team": {"billing"}}))
+	if _, err := c.ListApps(WithCallHeaders(http.Header{"x-canary": {"true"}})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type testContextKey string
+
+func TestContextHeaderMapping(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		json.NewEncoder(w).Encode([]App{})
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithContextHeaderMapping(map[any]string{
+		testContextKey("tenant"): "X-Tenant-Id",
+		testContextKey("trace"):  "X-Trace-Id",
+	}))
+	ctx := context.WithValue(context.Background(), testContextKey("tenant"), "acme")
+	if _, err := c.ListAppsContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got.Get("X-Tenant-Id") != "acme" {
+		t.Fatalf("expected tenant header, got %q", got.Get("X-Tenant-Id"))
+	}
+	if _, ok := got["X-Trace-Id"]; ok {
+		t.Fatal("expected no trace header when the context has no trace ID")
+	}
+}
+
+func TestDefaultQueryPrecedence(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("environment") != "sandbox" || q.Get("limit") != "5" || q.Get("since") != "evt_1" {
+			t.Fatalf("unexpected query %s", r.URL.RawQuery)
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithDefaultQuery(url.Values{
+		"environment": {"sandbox"},
+		"limit":       {"100"},
+		"since":       {"evt_0"},
+	}))
+	if _, err := c.ListEvents("evt_1", WithLimit(5)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListSubscribers(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/v1/apps/app-1/subscribers" || q.Get("from") != "2024-03-04T00:00:00Z" || q.Get("to") != "2024-03-11T00:00:00Z" {
+			t.Fatalf("unexpected request %s", r.URL)
+		}
+		if q.Get("limit") != "50" || q.Get("cursor") != "c1" {
+			t.Fatalf("expected pagination params, got %s", r.URL.RawQuery)
+		}
+		json.NewEncoder(w).Encode([]SubscriberInfo{{Subscriber: Subscriber{AppUserID: "user-1"}}})
+	})
+	defer srv.Close()
+
+	week := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	subs, err := c.ListSubscribers("app-1", week, week.AddDate(0, 0, 7), WithLimit(50), WithCursor("c1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 1 || subs[0].Subscriber.AppUserID != "user-1" {
+		t.Fatalf("unexpected subscribers %+v", subs)
+	}
+}
+
+func TestBulkDeleteSubscribersChunks(t *testing.T) {
+	var chunks []int
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/apps/app-1/subscribers/delete" {
+			t.Fatalf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		var body struct {
+			AppUserIDs []string `json:"app_user_ids"`
+			Prefix     string   `json:"app_user_id_prefix"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Prefix != "" {
+			json.NewEncoder(w).Encode(BulkResult{Results: []BulkItemResult{{ID: "acme-1", OK: true}}})
+			return
+		}
+		chunks = append(chunks, len(body.AppUserIDs))
+		var result BulkResult
+		for _, id := range body.AppUserIDs {
+			result.Results = append(result.Results, BulkItemResult{ID: id, OK: true})
+		}
+		json.NewEncoder(w).Encode(result)
+	})
+	defer srv.Close()
+
+	ids := make([]string, 250)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("user-%d", i)
+	}
+	result, err := c.BulkDeleteSubscribers("app-1", ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(chunks) != "[100 100 50]" || len(result.Results) != 250 {
+		t.Fatalf("unexpected chunks %v with %d results", chunks, len(result.Results))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.BulkDeleteSubscribersContext(ctx, "app-1", ids); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	if _, err := c.DeleteSubscribersWithPrefix("app-1", ""); err == nil {
+		t.Fatal("expected empty prefix to be rejected")
+	}
+	if result, err := c.DeleteSubscribersWithPrefix("app-1", "acme-"); err != nil || len(result.Results) != 1 {
+		t.Fatalf("unexpected prefix delete result %+v, %v", result, err)
+	}
+}
+
+func TestListExpiringSubscribers(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/apps/app-1/subscribers" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		q := r.URL.Query()
+		before, err := time.Parse(time.RFC3339, q.Get("expires_before"))
+		if err != nil || time.Until(before) < 6*24*time.Hour {
+			t.Fatalf("unexpected expires_before %q", q.Get("expires_before"))
+		}
+		if q.Get("will_renew") != "false" || q.Get("limit") != "100" {
+			t.Fatalf("unexpected query %s", r.URL.RawQuery)
+		}
+		json.NewEncoder(w).Encode([]SubscriberInfo{{Subscriber: Subscriber{ID: "s1"}}})
+	})
+	defer srv.Close()
+
+	subs, err := c.ListExpiringSubscribers("app-1", 7*24*time.Hour, WithLimit(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 1 {
+		t.Fatalf("expected 1 subscriber, got %d", len(subs))
+	}
+}
+
+func TestRecordPurchase(t *testing.T) {
+	var submitted map[string]string
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/v1/apps/app-1/products":
+			json.NewEncoder(w).Encode([]Product{{ID: "p1", StoreProductID: "com.example.pro"}})
+		case r.Method == "POST" && r.URL.Path == "/v1/receipts":
+			json.NewDecoder(r.Body).Decode(&submitted)
+			json.NewEncoder(w).Encode(Transaction{ID: "tx1"})
+		case r.Method == "GET" && r.URL.Path == "/v1/subscribers/user-1":
+			json.NewEncoder(w).Encode(SubscriberInfo{ActiveEntitlements: []EntitlementInfo{{ID: "pro", IsActive: true}}})
+		default:
+			t.Fatalf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+	})
+	defer srv.Close()
+
+	info, err := c.RecordPurchase("app-1", "user-1", "com.example.pro", AppleReceipt("receipt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if submitted["product_id"] != "p1" || len(info.ActiveEntitlements) != 1 {
+		t.Fatalf("unexpected submission %v or subscriber %+v", submitted, info)
+	}
+	if _, err := c.RecordPurchase("app-1", "user-1", "com.example.missing", AppleReceipt("receipt")); err == nil {
+		t.Fatal("expected error for unknown store product")
+	}
+}
+
+func TestCreateSubscriberIfMissing(t *testing.T) {
+	var got []any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		create, ok := body["create_subscriber"]
+		if !ok {
+			create = "unset"
+		}
+		got = append(got, create)
+		w.Write([]byte(`{"id":"tx-1"}`))
+	}))
+	defer srv.Close()
+
+	if _, err := NewClient(srv.URL, "test-key").SubmitReceipt("app-1", "user-1", "p1", AppleReceipt("data")); err != nil {
+		t.Fatal(err)
+	}
+	strict := NewClient(srv.URL, "test-key", WithDefaultCreateSubscriberIfMissing(false))
+	if _, err := strict.SubmitReceipt("app-1", "user-1", "p1", AppleReceipt("data")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := strict.SubmitReceipt("app-1", "user-1", "p1", AppleReceipt("data"), WithCreateSubscriberIfMissing(true)); err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != "unset" || got[1] != false || got[2] != true {
+		t.Fatalf("unexpected create_subscriber values %v", got)
+	}
+}
+
+func TestSubmitReceipts(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/receipts/batch" {
+			t.Fatalf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		var body struct {
+			Receipts []map[string]string `json:"receipts"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if len(body.Receipts) != 3 || body.Receipts[1]["store"] != "google" || body.Receipts[1]["package_name"] != "com.example" {
+			t.Fatalf("unexpected body %+v", body)
+		}
+		if body.Receipts[2]["app_user_id"] != "u3" || body.Receipts[2]["product_id"] != "pro" {
+			t.Fatalf("unexpected submission %v", body.Receipts[2])
+		}
+		w.Write([]byte(`{"results":[
+			{"index":2,"error":{"code":"store_unavailable","message":"timeout","retryable":true}},
+			{"index":0,"transaction":{"id":"tx1"}},
+			{"index":1,"error":{"code":"invalid_receipt","message":"bad signature","retryable":false}}
+		]}`))
+	})
+	defer srv.Close()
+
+	batch := []ReceiptSubmission{
+		{AppID: "app-1", AppUserID: "u1", ProductID: "pro", Receipt: AppleReceipt("r1")},
+		{AppID: "app-1", AppUserID: "u2", ProductID: "pro", Receipt: GoogleReceipt("com.example", "r2", "pro_monthly")},
+		{AppID: "app-1", AppUserID: "u3", ProductID: "pro", Receipt: AppleReceipt("r3")},
+	}
+	result, err := c.SubmitReceipts(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Failed()) != 2 {
+		t.Fatalf("expected 2 failures, got %+v", result.Failed())
+	}
+	retry := result.RetryableFailures(batch)
+	if len(retry) != 1 || retry[0].AppUserID != "u3" {
+		t.Fatalf("unexpected retry batch %+v", retry)
+	}
+
+	batch[0].Receipt = StripeReceipt("")
+	if _, err := c.SubmitReceipts(batch); err == nil || !strings.Contains(err.Error(), "receipt 0") {
+		t.Fatalf("expected error for incomplete receipt 0, got %v", err)
+	}
+}
+
+func TestReceiptGrantsEntitlement(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/receipts/entitlements" {
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["app_id"] != "app-1" || body["store"] != "apple" {
+			t.Fatalf("unexpected body %v", body)
+		}
+		if body["receipt_data"] == "bad" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`receipt rejected by store`))
+			return
+		}
+		w.Write([]byte(`{"entitlements":["pro","ad_free"]}`))
+	})
+	defer srv.Close()
+
+	if ok, err := c.ReceiptGrantsEntitlement("app-1", AppleReceipt("good"), "pro"); err != nil || !ok {
+		t.Fatalf("expected pro to be granted, got %v, %v", ok, err)
+	}
+	if ok, err := c.ReceiptGrantsEntitlement("app-1", AppleReceipt("good"), "gold"); err != nil || ok {
+		t.Fatalf("expected gold not to be granted, got %v, %v", ok, err)
+	}
+	if _, err := c.ReceiptGrantsEntitlement("app-1", AppleReceipt("bad"), "pro"); !errors.Is(err, ErrInvalidReceipt) {
+		t.Fatalf("expected ErrInvalidReceipt, got %v", err)
+	}
+}
+
+func TestSubmitGoogleReceipt(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["store"] != "google" || body["receipt_data"] != "tok" || body["package_name"] != "com.example" {
+			t.Fatalf("unexpected body %v", body)
+		}
+		if body["product_id"] != "p1" || body["store_product_id"] != "pro_monthly" {
+			t.Fatalf("expected distinct OpenCat and Play product IDs, got %v", body)
+		}
+		json.NewEncoder(w).Encode(Transaction{ID: "tx1", Store: "google"})
+	})
+	defer srv.Close()
+
+	tx, err := c.SubmitGoogleReceipt("app-1", "user-1", "com.example", "pro_monthly", "tok", "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Store != "google" {
+		t.Fatalf("expected google, got %s", tx.Store)
+	}
+	if _, err := c.SubmitGoogleReceipt("app-1", "user-1", "com.example", "pro_monthly", "", "p1"); err == nil {
+		t.Fatal("expected error for empty purchase token")
+	}
+}
+
+func TestPathTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key",
+		func(c *Client) { c.httpClient.Timeout = 10 * time.Millisecond },
+		WithPathTimeout("/v1/events", time.Second),
+		WithPathTimeout("/v1/apps", time.Second),
+		WithPathTimeout("/v1/apps/*/entitlements", 10*time.Millisecond),
+	)
+	if _, err := c.ListEvents(""); err != nil {
+		t.Fatalf("expected the path timeout to outlast the client timeout: %v", err)
+	}
+	if _, err := c.ListProducts("app-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ListEntitlements("app-1"); err == nil {
+		t.Fatal("expected the longest matching pattern to time out")
+	}
+	if _, err := c.ListWebhooks(); err == nil {
+		t.Fatal("expected the client timeout for unmatched paths")
+	}
+}
+
+func TestDeadlineHeader(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("X-Request-Timeout-Ms"))
+		json.NewEncoder(w).Encode([]App{})
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := NewClient(srv.URL, "test-key").ListAppsContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewClient(srv.URL, "test-key", WithDeadlineHeader()).ListAppsContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != "" {
+		t.Fatalf("expected no header by default, got %q", got[0])
+	}
+	ms, err := strconv.Atoi(got[1])
+	if err != nil || ms <= 0 || ms > 5000 {
+		t.Fatalf("unexpected X-Request-Timeout-Ms %q", got[1])
+	}
+}
+
+func TestMethodOverride(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.Header.Get("X-HTTP-Method-Override") != "PATCH" {
+			t.Fatalf("unexpected %s with override %q", r.Method, r.Header.Get("X-HTTP-Method-Override"))
+		}
+		w.WriteHeader(204)
+	}))
+	defer srv.Close()
+
+	name := "Jane"
+	c := NewClient(srv.URL, "test-key", WithMethodOverride())
+	if err := c.SetSubscriberProfile("user-1", SubscriberProfile{DisplayName: &name}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAutoIdempotency(t *testing.T) {
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		json.NewEncoder(w).Encode(App{ID: "app-1"})
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithAutoIdempotency())
+	c.CreateApp("Demo", "ios", "com.example.demo")
+	c.CreateApp("Demo", "ios", "com.example.demo")
+	c.CreateApp("Other", "ios", "com.example.other")
+	c.CreateApp("Demo", "ios", "com.example.demo", WithIdempotencyKey("manual"))
+	c.ListApps()
+
+	if keys[0] == "" || keys[0] != keys[1] {
+		t.Fatalf("expected identical calls to share a key, got %q and %q", keys[0], keys[1])
+	}
+	if keys[2] == keys[0] {
+		t.Fatal("expected a different body to get a different key")
+	}
+	if keys[3] != "manual" || keys[4] != "" {
+		t.Fatalf("expected override and no key on GET, got %q and %q", keys[3], keys[4])
+	}
+}
+
+func TestCreateWebhookWithAutoDisableThreshold(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["url"] != "https://hook.example.com" || body["auto_disable_threshold"] != float64(5) {
+			t.Fatalf("unexpected body %v", body)
+		}
+		json.NewEncoder(w).Encode(WebhookEndpoint{ID: "w1"})
+	})
+	defer srv.Close()
+
+	if _, err := c.CreateWebhook("app-1", "https://hook.example.com", WithAutoDisableThreshold(5)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBodyFieldOptionsSkipReads(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if r.Method == "GET" && len(b) > 0 {
+			t.Fatalf("GET sent a body: %s", b)
+		}
+		json.NewEncoder(w).Encode([]WebhookEndpoint{})
+	})
+	defer srv.Close()
+
+	opts := []CallOption{WithAutoDisableThreshold(5), WithExtraFields(map[string]any{"team_id": "t1"})}
+	if _, err := c.ListWebhooks(opts...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebhookCustomHeaders(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			CustomHeaders map[string]string `json:"custom_headers"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.CustomHeaders["X-Gateway-Token"] != "tok" {
+			t.Fatalf("unexpected body %+v", body)
+		}
+		w.Write([]byte(`{"id":"w1","custom_headers":{"X-Gateway-Token":"********"}}`))
+	})
+	defer srv.Close()
+
+	headers := map[string]string{"X-Gateway-Token": "tok"}
+	wh, err := c.CreateWebhook("app-1", "https://example.com/hook", WithCustomHeaders(headers))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := wh.CustomHeaders["X-Gateway-Token"]; !ok {
+		t.Fatalf("expected header name to be listed, got %v", wh.CustomHeaders)
+	}
+	if _, err := c.UpdateWebhook("w1", WebhookUpdate{CustomHeaders: headers}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateWebhook(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" || r.URL.Path != "/v1/webhooks/w1" {
+			t.Fatalf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if len(body) != 1 || body["auto_disable_threshold"] != float64(3) {
+			t.Fatalf("unexpected body %v", body)
+		}
+		json.NewEncoder(w).Encode(WebhookEndpoint{ID: "w1"})
+	})
+	defer srv.Close()
+
+	threshold := 3
+	if _, err := c.UpdateWebhook("w1", WebhookUpdate{AutoDisableThreshold: &threshold}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateWebhookURLAndActive(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if len(body) != 2 || body["url"] != "https://example.com/v2" || body["active"] != false {
+			t.Fatalf("unexpected body %v", body)
+		}
+		json.NewEncoder(w).Encode(WebhookEndpoint{ID: "w1", URL: "https://example.com/v2"})
+	})
+	defer srv.Close()
+
+	u, active := "https://example.com/v2", false
+	wh, err := c.UpdateWebhook("w1", WebhookUpdate{URL: &u, Active: &active})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wh.URL != u {
+		t.Fatalf("unexpected URL %q", wh.URL)
+	}
+}
+
+func TestUpdateEntitlement(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" || r.URL.Path != "/v1/apps/app-1/entitlements/e1" {
+			t.Fatalf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if len(body) != 1 || body["description"] != "All features" {
+			t.Fatalf("unexpected body %v", body)
+		}
+		json.NewEncoder(w).Encode(Entitlement{ID: "e1", Name: "premium"})
+	})
+	defer srv.Close()
+
+	desc := "All features"
+	if _, err := c.UpdateEntitlement("app-1", "e1", EntitlementUpdate{Description: &desc}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnableWebhook(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/webhooks/w1/enable" {
+			t.Fatalf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(WebhookEndpoint{ID: "w1", Active: true})
+	})
+	defer srv.Close()
+
+	wh, err := c.EnableWebhook("w1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wh.Active || wh.ConsecutiveFailures != 0 {
+		t.Fatalf("unexpected endpoint %+v", wh)
+	}
+}
+
+func TestListEntitlementSubscribers(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/apps/app-1/entitlements/e1/subscribers" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("as_of") != "2024-03-01T00:00:00Z" {
+			t.Fatalf("unexpected query %s", r.URL.RawQuery)
+		}
+		json.NewEncoder(w).Encode([]Subscriber{{ID: "s1", AppUserID: "user-1"}})
+	})
+	defer srv.Close()
+
+	subs, err := c.ListEntitlementSubscribers("app-1", "e1", WithAsOf(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 1 || subs[0].AppUserID != "user-1" {
+		t.Fatalf("unexpected subscribers %+v", subs)
+	}
+}
+
+func TestListEntitlementChanges(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/v1/apps/app-1/entitlements/changes" || q.Get("from") != "2024-03-01T00:00:00Z" || q.Get("to") != "2024-03-02T00:00:00Z" {
+			t.Fatalf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`[{"app_user_id":"user-1","entitlement_id":"pro","type":"revoked","occurred_at":"2024-03-01T10:00:00Z"}]`))
+	})
+	defer srv.Close()
+
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	changes, err := c.ListEntitlementChanges("app-1", day, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Type != EntitlementRevoked {
+		t.Fatalf("unexpected changes %+v", changes)
+	}
+}
+
+func TestBulkUpdateProductEntitlements(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/apps/app-1/products/entitlements" {
+			t.Fatalf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		var body struct {
+			Updates []struct {
+				ProductID      string   `json:"product_id"`
+				EntitlementIDs []string `json:"entitlement_ids"`
+			} `json:"updates"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if len(body.Updates) != 2 {
+			t.Fatalf("expected 2 updates, got %d", len(body.Updates))
+		}
+		msg := "product not found"
+		json.NewEncoder(w).Encode(BulkResult{Results: []BulkItemResult{
+			{ID: "p1", OK: true},
+			{ID: "p2", OK: false, Error: &msg},
+		}})
+	})
+	defer srv.Close()
+
+	res, err := c.BulkUpdateProductEntitlements("app-1", map[string][]string{
+		"p1": {"e1", "e2"},
+		"p2": {"e1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := res.Failed()
+	if len(failed) != 1 || failed[0].ID != "p2" {
+		t.Fatalf("unexpected failures %+v", failed)
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/webhooks/validate" {
+			t.Fatalf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["url"] != "https://hooks.example.com/opencat" {
+			t.Fatalf("unexpected body %v", body)
+		}
+		w.Write([]byte(`{"url":"https://hooks.example.com/opencat","reachable":false,"status_code":0,"latency_ms":120,"tls_error":"x509: certificate has expired"}`))
+	})
+	defer srv.Close()
+
+	v, err := c.ValidateWebhookURL("https://hooks.example.com/opencat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Reachable || v.TLSError == nil || v.Latency() != 120*time.Millisecond {
+		t.Fatalf("unexpected validation %+v", v)
+	}
+	if _, err := c.ValidateWebhookURL("hooks.example.com/opencat"); err == nil {
+		t.Fatal("expected error for URL without scheme")
+	}
+}
+
+func TestListFailedWebhookDeliveries(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/v1/webhooks/wh1/deliveries" || q.Get("status") != "failed,dead_letter" {
+			t.Fatalf("unexpected request %s", r.URL)
+		}
+		if q.Get("from") != "2024-03-01T00:00:00Z" || q.Get("to") != "" || q.Get("limit") != "100" {
+			t.Fatalf("unexpected filters %s", r.URL.RawQuery)
+		}
+		w.Write([]byte(`[{"id":"d1","webhook_endpoint_id":"wh1","event_id":"ev1","status":"dead_letter","attempts":5,"last_error":"HTTP 500"}]`))
+	})
+	defer srv.Close()
+
+	since := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	deliveries, err := c.ListFailedWebhookDeliveries("wh1", WithDateRange(since, time.Time{}), WithLimit(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Status != DeliveryDeadLetter || deliveries[0].Attempts != 5 {
+		t.Fatalf("unexpected deliveries %+v", deliveries)
+	}
+}
+
+func TestListWebhooksRedactsSecrets(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":"w1","secret":"sec"}]`))
+	})
+	defer srv.Close()
+
+	hidden, err := c.ListWebhooks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hidden[0].Secret != "" {
+		t.Fatalf("expected secret to be omitted, got %q", hidden[0].Secret.Reveal())
+	}
+	revealed, err := c.ListWebhooks(WithReveal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revealed[0].Secret.Reveal() != "sec" {
+		t.Fatalf("expected revealed secret, got %q", revealed[0].Secret.Reveal())
+	}
+}
+
+func TestGetWebhookSecret(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/webhooks/w1/secret" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"secret":"sec"}`))
+	})
+	defer srv.Close()
+
+	secret, err := c.GetWebhookSecret("w1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Reveal() != "sec" {
+		t.Fatalf("expected sec, got %q", secret.Reveal())
+	}
+}
+
+func TestSecretIsRedactedWhenPrinted(t *testing.T) {
+	wh := WebhookEndpoint{ID: "w1", Secret: "sec"}
+	if s := fmt.Sprintf("%v %+v %#v %s", wh, wh, wh, wh.Secret); strings.Contains(s, "sec\"") || strings.Contains(s, " sec") {
+		t.Fatalf("secret leaked: %s", s)
+	}
+	b, _ := json.Marshal(wh)
+	if strings.Contains(string(b), `"sec"`) {
+		t.Fatalf("secret leaked: %s", b)
+	}
+}
+
+func TestMarshalRevealedJSON(t *testing.T) {
+	wh := WebhookEndpoint{ID: "w1", Secret: "sec", CustomHeaders: map[string]Secret{"X-Token": "tok"}}
+
+	b, err := json.Marshal(wh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var redacted WebhookEndpoint
+	if err := json.Unmarshal(b, &redacted); err != nil {
+		t.Fatal(err)
+	}
+	if redacted.Secret.Reveal() != "[REDACTED]" || redacted.CustomHeaders["X-Token"].Reveal() != "[REDACTED]" {
+		t.Fatalf("expected json.Marshal to redact, got %s", b)
+	}
+
+	b, err = wh.MarshalRevealedJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var revealed WebhookEndpoint
+	if err := json.Unmarshal(b, &revealed); err != nil {
+		t.Fatal(err)
+	}
+	if revealed.ID != "w1" || revealed.Secret.Reveal() != "sec" || revealed.CustomHeaders["X-Token"].Reveal() != "tok" {
+		t.Fatalf("expected secrets to survive, got %s", b)
+	}
+}
+
+func TestWithRegion(t *testing.T) {
+	c := NewClient("https://api.example.com/", "test-key", WithRegion("eu"))
+	if c.Region() != "eu" || c.baseURL != "https://eu.api.example.com" {
+		t.Fatalf("unexpected region %q, base URL %q", c.Region(), c.baseURL)
+	}
+	for _, selfHosted := range []string{"http://localhost:8080", "http://127.0.0.1:8080", "http://[::1]:8080", "http://opencat:8080"} {
+		if c := NewClient(selfHosted, "test-key", WithRegion("eu")); c.baseURL != selfHosted {
+			t.Fatalf("expected %s to be left as is, got %q", selfHosted, c.baseURL)
+		}
+	}
+	if c := NewClient("https://api.example.com", "test-key", WithRegion("")); c.Region() != "" || c.baseURL != "https://api.example.com" {
+		t.Fatalf("expected an empty region to be a no-op, got %q, base URL %q", c.Region(), c.baseURL)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Data-Region") != "eu" {
+			t.Fatalf("missing region header")
+		}
+		w.WriteHeader(421)
+	}))
+	defer srv.Close()
+
+	c = NewClient(srv.URL, "test-key", WithRegion("eu"))
+	if _, err := c.GetSubscriber("user-1"); !IsWrongRegion(err) {
+		t.Fatalf("expected wrong-region error, got %v", err)
+	}
+}
+
+func TestGetTrialConversion(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/apps/app-1/metrics/trial-conversion" || r.URL.Query().Get("from") == "" {
+			t.Fatalf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`[{"product_id":"p1","trials_started":40,"converted":10,"rate":0.25}]`))
+	})
+	defer srv.Close()
+
+	stats, err := c.GetTrialConversion("app-1", time.Now().AddDate(0, -1, 0), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].Converted != 10 || stats[0].Rate != 0.25 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestGetProductRevenue(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/v1/apps/app-1/products/p1/revenue" || q.Get("granularity") != "week" || q.Get("to") == "" {
+			t.Fatalf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`[{"start":"2024-03-04T00:00:00Z","currency":"USD","gross_micros":50000000,"net_micros":40000000}]`))
+	})
+	defer srv.Close()
+
+	points, err := c.GetProductRevenue("app-1", "p1", time.Now().AddDate(0, -1, 0), time.Now(), GranularityWeek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 1 || points[0].NetMicros != 40000000 {
+		t.Fatalf("unexpected points %+v", points)
+	}
+}
+
+func TestGetSubscriberLTV(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/subscribers/user-1/ltv" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"total_micros":19980000,"currency":"USD","by_currency":{"USD":9990000,"EUR":8990000},"transaction_count":2}`))
+	})
+	defer srv.Close()
+
+	ltv, err := c.GetSubscriberLTV("user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ltv.TotalMicros != 19980000 || ltv.ByCurrency["EUR"] != 8990000 || ltv.TransactionCount != 2 {
+		t.Fatalf("unexpected LTV %+v", ltv)
+	}
+}
+
+func TestGetSubscriptionHistory(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/subscribers/user-1/subscriptions/pro_monthly/history" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"app_user_id":"user-1","product_id":"pro_monthly","renewal_count":2,"timeline":[
+			{"type":"purchase","occurred_at":"2024-01-01T00:00:00Z","price_micros":9990000,"currency":"USD"},
+			{"type":"renewal","occurred_at":"2024-02-01T00:00:00Z","price_micros":9990000,"currency":"USD"},
+			{"type":"cancellation","occurred_at":"2024-02-10T00:00:00Z"},
+			{"type":"reactivation","occurred_at":"2024-02-20T00:00:00Z"},
+			{"type":"renewal","occurred_at":"2024-03-01T00:00:00Z","price_micros":12990000,"currency":"USD"}
+		]}`))
+	})
+	defer srv.Close()
+
+	h, err := c.GetSubscriptionHistory("user-1", "pro_monthly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewals := h.Renewals()
+	if h.RenewalCount != 2 || len(renewals) != 2 || *renewals[1].PriceMicros != 12990000 {
+		t.Fatalf("unexpected history %+v", h)
+	}
+	if h.Timeline[0].Type != SubscriptionPurchased {
+		t.Fatalf("expected purchase first, got %s", h.Timeline[0].Type)
+	}
+}
+
+func TestListEventsOrder(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("order") != "desc" || q.Get("since") != "2024-01-01T00:00:00Z" {
+			t.Fatalf("unexpected query %s", r.URL.RawQuery)
+		}
+		json.NewEncoder(w).Encode([]Event{})
+	})
+	defer srv.Close()
+
+	if _, err := c.ListEvents("2024-01-01T00:00:00Z", WithOrder(OrderDesc)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListEventsFilters(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("since") != "ev9" || q.Get("event_type") != "CANCELLATION,EXPIRATION" || q.Get("subscriber_id") != "s1" || q.Get("limit") != "20" {
+			t.Fatalf("unexpected query %s", r.URL.RawQuery)
+		}
+		json.NewEncoder(w).Encode([]Event{})
+	})
+	defer srv.Close()
+
+	if _, err := c.ListEvents("ev9", WithEventType(EventCancellation, EventExpiration), WithSubscriberID("s1"), WithLimit(20)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLatestEventCursor(t *testing.T) {
+	empty := false
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/events" || r.URL.RawQuery != "limit=1" {
+			t.Fatalf("unexpected request %s", r.URL)
+		}
+		if empty {
+			w.Write([]byte(`[]`))
+			return
+		}
+		w.Write([]byte(`[{"id":"ev42","created_at":"2026-01-01T00:00:42+00:00"}]`))
+	})
+	defer srv.Close()
+
+	if cursor, err := c.LatestEventCursor(); err != nil || cursor != "2026-01-01T00:00:42+00:00" {
+		t.Fatalf("expected the newest event's created_at, got %q, %v", cursor, err)
+	}
+	opts := make([]CallOption, 0, 4)
+	if _, err := c.LatestEventCursor(opts...); err != nil {
+		t.Fatal(err)
+	}
+	if opts[:1][0] != nil {
+		t.Fatal("expected the caller's options slice not to be written to")
+	}
+	if _, err := c.LatestEventCursor(WithOrder(OrderAsc), WithCursor("ev1")); err != nil {
+		t.Fatal(err)
+	}
+	empty = true
+	if cursor, err := c.LatestEventCursor(); err != nil || cursor != "" {
+		t.Fatalf("expected empty cursor, got %q, %v", cursor, err)
+	}
+}
+
+func TestCaptureRaw(t *testing.T) {
+	body := `{"subscriber":{"id":"s1","app_user_id":"user-1","unknown_field":1},"active_entitlements":[],"transactions":[]}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	var resp Response
+	if _, err := NewClient(srv.URL, "test-key").GetSubscriber("user-1", WithResponse(&resp)); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Raw != nil {
+		t.Fatal("expected no raw body without WithCaptureRaw")
+	}
+
+	info, err := NewClient(srv.URL, "test-key", WithCaptureRaw()).GetSubscriber("user-1", WithResponse(&resp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Raw) != body || info.Subscriber.ID != "s1" {
+		t.Fatalf("unexpected raw body %s", resp.Raw)
+	}
+}
+
+func TestResponseMetadata(t *testing.T) {
+	reset := time.Now().Add(time.Minute).Truncate(time.Second)
+	status := http.StatusOK
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-123")
+		w.Header().Set("X-RateLimit-Remaining", "41")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		w.WriteHeader(status)
+		w.Write([]byte(`{"code":"internal","message":"boom"}`))
+	})
+	defer srv.Close()
+
+	var resp Response
+	if _, err := c.GetSubscriber("user-1", WithResponse(&resp)); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.RequestID != "req-123" || resp.RateLimitRemaining != 41 || !resp.RateLimitReset.Equal(reset) {
+		t.Fatalf("unexpected response metadata %+v", resp)
+	}
+
+	status = http.StatusBadRequest
+	_, err := c.GetSubscriber("user-1", WithResponse(&resp))
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.RequestID != "req-123" {
+		t.Fatalf("expected the request ID on the error, got %v", err)
+	}
+	if got := apiErr.Error(); got != "HTTP 400: internal: boom (request req-123)" {
+		t.Fatalf("unexpected error string %q", got)
+	}
+	if resp.StatusCode != http.StatusBadRequest || resp.RequestID != "req-123" {
+		t.Fatalf("expected metadata for a failed call, got %+v", resp)
+	}
+}
+
+func TestSubmitAmazonReceipt(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["store"] != "amazon" || body["receipt_data"] != "rcpt" || body["amazon_user_id"] != "amzn-user" {
+			t.Fatalf("unexpected body %v", body)
+		}
+		json.NewEncoder(w).Encode(Transaction{ID: "tx1", Store: StoreAmazon})
+	})
+	defer srv.Close()
+
+	tx, err := c.SubmitAmazonReceipt("app-1", "user-1", "amzn-user", "rcpt", "pro_monthly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Store != StoreAmazon {
+		t.Fatalf("expected amazon, got %s", tx.Store)
+	}
+}
+
+func TestSubmitReceiptRejectsNilReceipt(t *testing.T) {
+	c := NewClient("http://unused", "test-key")
+	if _, err := c.SubmitReceipt("app-1", "user-1", "p1", nil); err == nil {
+		t.Fatal("expected error for nil receipt")
+	}
+}
+
+func TestGetMRRMovement(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/apps/app-1/metrics/mrr-movement" || r.URL.Query().Get("from") != "2024-01-01T00:00:00Z" {
+			t.Fatalf("unexpected request %s", r.URL)
+		}
+		json.NewEncoder(w).Encode(MRRMovement{Currency: "USD", NewMicros: 100, ChurnMicros: 40, NetMicros: 60})
+	})
+	defer srv.Close()
+
+	m, err := c.GetMRRMovement("app-1", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.NetMicros != 60 || m.Currency != "USD" {
+		t.Fatalf("unexpected movement %+v", m)
+	}
+}
+
+func TestMaxConcurrency(t *testing.T) {
+	release := make(chan struct{})
+	arrived := make(chan struct{}, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+		json.NewEncoder(w).Encode([]App{})
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithMaxConcurrency(1))
+	done := make(chan error)
+	go func() {
+		_, err := c.ListApps()
+		done <- err
+	}()
+	<-arrived
+	if c.InFlight() != 1 {
+		t.Fatalf("expected 1 in flight, got %d", c.InFlight())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.ListAppsContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded while queued, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if c.InFlight() != 0 {
+		t.Fatalf("expected 0 in flight, got %d", c.InFlight())
+	}
+}
+
+func TestMaxConcurrencyNonPositiveIsUnlimited(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]App{})
+	}))
+	defer srv.Close()
+
+	for _, n := range []int{0, -1} {
+		c := NewClient(srv.URL, "test-key", WithMaxConcurrency(n))
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err := c.ListAppsContext(ctx)
+		cancel()
+		if err != nil {
+			t.Fatalf("WithMaxConcurrency(%d): %v", n, err)
+		}
+	}
+}
+
+func TestHasTransaction(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/v1/transactions/lookup" || q.Get("store") != "apple" {
+			t.Fatalf("unexpected request %s", r.URL)
+		}
+		if q.Get("store_transaction_id") != "1000" {
+			w.WriteHeader(404)
+			return
+		}
+		w.Write([]byte(`{"id":"tx1"}`))
+	})
+	defer srv.Close()
+
+	id, ok, err := c.FindTransactionID("apple", "1000")
+	if err != nil || !ok || id != "tx1" {
+		t.Fatalf("expected tx1, got %q %v %v", id, ok, err)
+	}
+	ok, err = c.HasTransaction("apple", "2000")
+	if err != nil || ok {
+		t.Fatalf("expected missing transaction, got %v %v", ok, err)
+	}
+}
+
+func TestExtraFields(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		json.NewEncoder(w).Encode(App{ID: "app-1"})
+	}))
+	defer srv.Close()
+
+	extra := WithExtraFields(map[string]any{"teamID": "t1", "name": "ignored"})
+	if _, err := NewClient(srv.URL, "test-key").CreateApp("A", "ios", "com.a", extra); err != nil {
+		t.Fatal(err)
+	}
+	if body["teamID"] != "t1" || body["name"] != "A" {
+		t.Fatalf("unexpected body %v", body)
+	}
+
+	c := NewClient(srv.URL, "test-key", WithExtraFieldNaming(NormalizeSnakeCase))
+	if _, err := c.CreateApp("A", "ios", "com.a", extra); err != nil {
+		t.Fatal(err)
+	}
+	if body["team_id"] != "t1" || body["teamID"] != nil {
+		t.Fatalf("unexpected body %v", body)
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for in, want := range map[string]string{
+		"promoCode":  "promo_code",
+		"PromoCode":  "promo_code",
+		"userID":     "user_id",
+		"HTTPHeader": "http_header",
+		"already_ok": "already_ok",
+		"utm2Source": "utm2_source",
+		"ad-group":   "ad_group",
+	} {
+		if got := snakeCase(in); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestGrantEntitlementDryRun(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/subscribers/user-1/entitlements/e1/grant" || r.URL.Query().Get("dry_run") != "true" {
+			t.Fatalf("unexpected request %s", r.URL)
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["duration_seconds"] != float64(7*24*3600) {
+			t.Fatalf("unexpected body %v", body)
+		}
+		exp := "2024-03-05T00:00:00Z"
+		json.NewEncoder(w).Encode(EntitlementGrant{
+			Entitlement: EntitlementInfo{ID: "e1", IsActive: true, ExpirationDate: &exp},
+			Stacked:     true,
+		})
+	})
+	defer srv.Close()
+
+	grant, err := c.GrantEntitlement("user-1", "e1", 7*24*time.Hour, DryRun())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !grant.Preview || !grant.Stacked || *grant.Entitlement.ExpirationDate != "2024-03-05T00:00:00Z" {
+		t.Fatalf("unexpected grant %+v", grant)
+	}
+}
+
+func TestGrantEntitlementDryRunFailureIsNotPreview(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"entitlement not found"}`))
+	})
+	defer srv.Close()
+
+	grant, err := c.GrantEntitlement("user-1", "e1", time.Hour, DryRun())
+	if !IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if grant.Preview {
+		t.Fatal("expected a failed dry run not to be marked as a preview")
+	}
+}
+
+func TestGetWebhookHealth(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/webhooks/w1/health" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"webhook_id":"w1","success_rate":0.5,"deliveries":10,"consecutive_failures":4,"last_failure_at":"t","healthy":false}`))
+	})
+	defer srv.Close()
+
+	h, err := c.GetWebhookHealth("w1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Healthy || h.SuccessRate != 0.5 || h.ConsecutiveFailures != 4 || h.LastSuccessAt != nil {
+		t.Fatalf("unexpected health %+v", h)
+	}
+}
+
+func TestCreateEntitlementUpsert(t *testing.T) {
+	var posts int
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			posts++
+			json.NewEncoder(w).Encode(Entitlement{ID: "e2", Name: "basic"})
+			return
+		}
+		json.NewEncoder(w).Encode([]Entitlement{{ID: "e1", Name: "pro"}})
+	})
+	defer srv.Close()
+
+	var resp Response
+	e, err := c.CreateEntitlement("app-1", "pro", nil, WithUpsert(), WithResponse(&resp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.ID != "e1" || resp.Created || posts != 0 {
+		t.Fatalf("expected existing e1, got %+v created=%v posts=%d", e, resp.Created, posts)
+	}
+
+	e, err = c.CreateEntitlement("app-1", "basic", nil, WithUpsert(), WithResponse(&resp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.ID != "e2" || !resp.Created || posts != 1 {
+		t.Fatalf("expected new e2, got %+v created=%v posts=%d", e, resp.Created, posts)
+	}
+}
+
+func TestUpsertLookupOptions(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			t.Fatal("unexpected create")
+		}
+		if r.URL.RawQuery != "" || r.Header.Get("X-Tenant") != "t1" || r.Header.Get(idempotencyKeyHeader) != "" {
+			t.Errorf("expected only the call headers on the lookup, got %s %v", r.URL.RawQuery, r.Header)
+		}
+		json.NewEncoder(w).Encode([]Entitlement{{ID: "e1", Name: "pro"}})
+	})
+	defer srv.Close()
+
+	resp := Response{StatusCode: 201, Created: true}
+	e, err := c.CreateEntitlement("app-1", "pro", nil, WithUpsert(), DryRun(), WithExtraFields(map[string]any{"note": "x"}),
+		WithIdempotencyKey("k1"), WithCallHeaders(http.Header{"X-Tenant": {"t1"}}), WithResponse(&resp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.ID != "e1" || resp.StatusCode != 0 || resp.Created {
+		t.Fatalf("expected existing e1 with an empty response, got %+v, %+v", e, resp)
+	}
+}
+
+func TestCreateProductUpsert(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			t.Fatal("unexpected create")
+		}
+		json.NewEncoder(w).Encode([]Product{{ID: "p1", StoreProductID: "com.example.pro"}})
+	})
+	defer srv.Close()
+
+	p, err := c.CreateProduct("app-1", "com.example.pro", "subscription", nil, WithUpsert())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != "p1" {
+		t.Fatalf("expected p1, got %s", p.ID)
+	}
+}
+
+func TestAckEvents(t *testing.T) {
+	var bodies []map[string]any
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/events/ack" || r.URL.Query().Get("consumer") != "billing" {
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL)
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		w.WriteHeader(204)
+	})
+	defer srv.Close()
+
+	if err := c.AckEvents([]string{"ev1", "ev2"}, WithConsumer("billing")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AckThrough("ev9", WithConsumer("billing")); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies[0]["event_ids"].([]any)) != 2 || bodies[1]["through"] != "ev9" {
+		t.Fatalf("unexpected bodies %v", bodies)
+	}
+}
+
+func TestGetEntitlementDistribution(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/apps/app-1/metrics/entitlement-distribution" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"pro":12,"basic":30,"none":58}`))
+	})
+	defer srv.Close()
+
+	dist, err := c.GetEntitlementDistribution("app-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dist["pro"] != 12 || dist["none"] != 58 {
+		t.Fatalf("unexpected distribution %v", dist)
+	}
+}
+
+func TestGetSubscribersByStore(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/apps/app-1/metrics/subscribers-by-store" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"apple":40,"google":25,"stripe":3}`))
+	})
+	defer srv.Close()
+
+	counts, err := c.GetSubscribersByStore("app-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts["apple"] != 40 || counts["stripe"] != 3 {
+		t.Fatalf("unexpected counts %v", counts)
+	}
+}
+
+func TestCreateAnonymousSubscriber(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/apps/app-1/subscribers/anonymous" {
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"id":"s1","app_id":"app-1","app_user_id":"$anonymous:3f2b9c0e8d7a41e6b5c4d3e2f1a0b9c8"}`))
+	})
+	defer srv.Close()
+
+	sub, err := c.CreateAnonymousSubscriber("app-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sub.IsAnonymous() {
+		t.Fatalf("expected an anonymous subscriber, got %q", sub.AppUserID)
+	}
+	if (&Subscriber{AppUserID: "user-1"}).IsAnonymous() {
+		t.Fatal("expected a regular app user ID not to be anonymous")
+	}
+}
+
+func TestSubscriberExists(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "HEAD" {
+			t.Fatalf("unexpected method %s", r.Method)
+		}
+		if r.URL.Path != "/v1/subscribers/user-1" {
+			w.WriteHeader(404)
+		}
+	})
+	defer srv.Close()
+
+	if ok, err := c.SubscriberExists("user-1"); err != nil || !ok {
+		t.Fatalf("expected user-1 to exist, got %v, %v", ok, err)
+	}
+	if ok, err := c.SubscriberExists("user-2"); err != nil || ok {
+		t.Fatalf("expected user-2 not to exist, got %v, %v", ok, err)
+	}
+}
+
+func TestGetActiveEntitlements(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/subscribers/user-1/entitlements/active" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"entitlements":{"pro":true,"legacy":false}}`))
+	})
+	defer srv.Close()
+
+	active, err := c.GetActiveEntitlements("user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !active["pro"] || active["legacy"] || active["missing"] {
+		t.Fatalf("unexpected entitlements %v", active)
+	}
+}
+
+func TestSubscriberEntitlementChangesSince(t *testing.T) {
+	since := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	changed := true
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/subscribers/user-1/entitlements/changes" || r.URL.Query().Get("since") != "2026-03-01T12:00:00Z" {
+			t.Fatalf("unexpected request %s", r.URL)
+		}
+		if !changed {
+			w.Write([]byte(`null`))
+			return
+		}
+		w.Write([]byte(`[{"app_user_id":"user-1","entitlement_id":"e1","type":"revoked","occurred_at":"2026-03-02T00:00:00Z"}]`))
+	})
+	defer srv.Close()
+
+	changes, err := c.SubscriberEntitlementChangesSince("user-1", since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Type != EntitlementRevoked {
+		t.Fatalf("unexpected changes %+v", changes)
+	}
+
+	changed = false
+	changes, err = c.SubscriberEntitlementChangesSince("user-1", since)
+	if err != nil || changes == nil || len(changes) != 0 {
+		t.Fatalf("expected an empty slice, got %#v, %v", changes, err)
+	}
+}
+
+func TestGetUserEntitlementsAcrossApps(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/subscribers/user-1/entitlements" || r.URL.Query().Get("app_ids") != "app-1,app-2" {
+			t.Fatalf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"apps":{"app-1":[{"id":"pro","is_active":true,"product_id":"p1","store":"apple"}]}}`))
+	})
+	defer srv.Close()
+
+	byApp, err := c.GetUserEntitlementsAcrossApps("user-1", []string{"app-1", "app-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byApp["app-1"]) != 1 || !byApp["app-1"][0].IsActive {
+		t.Fatalf("unexpected app-1 entitlements %+v", byApp["app-1"])
+	}
+	if ents, ok := byApp["app-2"]; !ok || ents == nil || len(ents) != 0 {
+		t.Fatalf("expected an empty slice for app-2, got %#v", ents)
+	}
+}
+
+func TestSetSubscriberProfile(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" || r.URL.Path != "/v1/subscribers/user-1/profile" {
+			t.Fatalf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if len(body) != 1 || body["email"] != "jane@example.com" {
+			t.Fatalf("unexpected body %v", body)
+		}
+		w.WriteHeader(204)
+	})
+	defer srv.Close()
+
+	email := "jane@example.com"
+	if err := c.SetSubscriberProfile("user-1", SubscriberProfile{Email: &email}); err != nil {
+		t.Fatal(err)
+	}
+	bad := "not-an-email"
+	if err := c.SetSubscriberProfile("user-1", SubscriberProfile{Email: &bad}); err == nil {
+		t.Fatal("expected validation error")
+	}
+}
+
+func TestSetSubscriberAttribution(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/v1/subscribers/user-1/attribution" {
+			t.Fatalf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["network"] != "meta" || body["ad_group"] != "lookalike" || body["keyword"] != nil {
+			t.Fatalf("unexpected body %v", body)
+		}
+		w.WriteHeader(204)
+	})
+	defer srv.Close()
+
+	err := c.SetSubscriberAttribution("user-1", Attribution{Network: "meta", Campaign: "spring", AdGroup: "lookalike"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListProductTransactions(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/v1/apps/app-1/transactions" || q.Get("product_id") != "p1" || q.Get("from") == "" || q.Get("status") != "" {
+			t.Fatalf("unexpected request %s", r.URL)
+		}
+		json.NewEncoder(w).Encode([]Transaction{{ID: "tx1", ProductID: "p1"}})
+	})
+	defer srv.Close()
+
+	txs, err := c.ListProductTransactions("app-1", "p1", time.Now().Add(-24*time.Hour), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 1 {
+		t.Fatalf("expected 1 transaction, got %d", len(txs))
+	}
+}
+
+func TestRevalidateSubscriber(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/subscribers/user-1/revalidate" {
+			t.Fatalf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(SubscriberInfo{
+			Subscriber:         Subscriber{ID: "s1", AppUserID: "user-1"},
+			ActiveEntitlements: []EntitlementInfo{{ID: "e1", IsActive: true}},
+		})
+	})
+	defer srv.Close()
+
+	info, err := c.RevalidateSubscriber("user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.ActiveEntitlements) != 1 {
+		t.Fatalf("expected 1 active entitlement, got %d", len(info.ActiveEntitlements))
+	}
+}
+
+func TestListProductsWithSubscriberCounts(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("include") != "subscriber_counts" {
+			t.Fatalf("unexpected query %s", r.URL.RawQuery)
+		}
+		w.Write([]byte(`[{"id":"p1","active_subscriber_count":42}]`))
+	})
+	defer srv.Close()
+
+	products, err := c.ListProducts("app-1", WithSubscriberCounts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if products[0].ActiveSubscriberCount == nil || *products[0].ActiveSubscriberCount != 42 {
+		t.Fatalf("unexpected products %+v", products)
+	}
+}
+
+func TestListEventsWithFields(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/events":
+			if r.URL.Query().Get("fields") != "id,event_type" {
+				t.Fatalf("unexpected query %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`[{"id":"ev1","event_type":"purchase"}]`))
+		case "/v1/events/ev1":
+			json.NewEncoder(w).Encode(Event{ID: "ev1", EventType: "purchase", Payload: `{"big":true}`})
+		default:
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+	})
+	defer srv.Close()
+
+	events, err := c.ListEvents("", WithFields("id", "event_type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events[0].EventType != "purchase" || events[0].Payload != "" {
+		t.Fatalf("unexpected event %+v", events[0])
+	}
+	full, err := c.GetEvent(events[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full.Payload == "" {
+		t.Fatal("expected full payload")
+	}
+}
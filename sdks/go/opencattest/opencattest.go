@@ -0,0 +1,35 @@
+// Package opencattest builds webhook deliveries for testing webhook handlers
+// without a running OpenCat server.
+package opencattest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	opencat "github.com/opencat/opencat-go"
+)
+
+// NewSignedEvent returns the body and headers of a webhook delivery of
+// payload, as the server sends it to an endpoint with secret: the payload
+// itself, with no event envelope. The body is also signed with
+// SigningSHA256. payload is encoded as JSON unless it is already a
+// json.RawMessage; it panics if payload cannot be encoded.
+func NewSignedEvent(secret string, payload any) ([]byte, http.Header) {
+	body, ok := payload.(json.RawMessage)
+	if !ok {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			panic("opencattest: encoding payload: " + err.Error())
+		}
+	}
+
+	signature, err := opencat.SignWebhookPayload(opencat.SigningSHA256, body, secret)
+	if err != nil {
+		panic("opencattest: signing payload: " + err.Error())
+	}
+	h := http.Header{}
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Webhook-Secret", secret)
+	h.Set(opencat.SignatureHeader, signature)
+	return body, h
+}
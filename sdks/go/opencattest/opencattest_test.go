@@ -0,0 +1,28 @@
+package opencattest
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	opencat "github.com/opencat/opencat-go"
+)
+
+func TestNewSignedEventRoundTrip(t *testing.T) {
+	body, header := NewSignedEvent("sec", opencat.PayloadV2{AppID: "app-1", ProductID: "pro"})
+
+	if err := opencat.VerifyWebhookSignature(body, header.Get(opencat.SignatureHeader), "sec"); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("POST", "/hook", bytes.NewReader(body))
+	r.Header = header
+	payload, err := opencat.ParseWebhook(r, "sec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, ok := payload.(*opencat.PayloadV2)
+	if !ok || v2.AppID != "app-1" || v2.ProductID != "pro" {
+		t.Fatalf("unexpected payload %#v", payload)
+	}
+}
@@ -0,0 +1,623 @@
+package opencat
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type Option func(*Client)
+
+// WithHTTPClient makes the client send requests with hc instead of its own
+// *http.Client, for custom transports, proxies, or TLS settings.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		if hc != nil {
+			c.httpClient = hc
+			c.customHTTPClient = true
+		}
+	}
+}
+
+// WithTimeout replaces the default 30-second timeout of the client's own
+// *http.Client. It has no effect with WithHTTPClient, whose client keeps its
+// own timeout.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithUserAgent sets the User-Agent header of every request, so the server's
+// logs can identify the integration.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithRetry retries idempotent requests that fail with a transient error
+// (429, 5xx, or a network error), waiting about baseDelay, 2*baseDelay,
+// 4*baseDelay, ... between attempts, or as long as a 429's Retry-After header
+// asks. GET and HEAD requests are idempotent, as are others sent with an
+// Idempotency-Key (see WithIdempotencyKey and WithAutoIdempotency).
+// maxAttempts counts the initial attempt.
+func WithRetry(maxAttempts int, baseDelay time.Duration) Option {
+	return func(c *Client) {
+		c.retry = &retryPolicy{maxAttempts: maxAttempts, baseDelay: baseDelay}
+	}
+}
+
+// WithHeaders sets headers sent on every request made by the client.
+func WithHeaders(h http.Header) Option {
+	return func(c *Client) {
+		if c.headers == nil {
+			c.headers = http.Header{}
+		}
+		for k, v := range h {
+			c.headers[http.CanonicalHeaderKey(k)] = v
+		}
+	}
+}
+
+// WithContextHeaderMapping sends the value stored in the request context
+// under each key as the named header, formatted with fmt.Sprint. Keys with no
+// value in the context are skipped. Headers set with WithCallHeaders win.
+func WithContextHeaderMapping(mapping map[any]string) Option {
+	return func(c *Client) {
+		c.contextHeaders = mapping
+	}
+}
+
+// WithDefaultQuery adds query parameters to every request. A parameter the
+// method sets itself wins, then one set by a call option such as WithLimit,
+// and only then the default; values for a key are never combined.
+func WithDefaultQuery(query url.Values) Option {
+	return func(c *Client) {
+		c.defaultQuery = query
+	}
+}
+
+// WithDefaultProductType sets the product type CreateProduct uses when
+// called with an empty one.
+func WithDefaultProductType(t ProductType) Option {
+	return func(c *Client) {
+		c.defaultProductType = t
+	}
+}
+
+// WithDefaultCreateSubscriberIfMissing sets WithCreateSubscriberIfMissing
+// for every receipt submission that does not set it itself.
+func WithDefaultCreateSubscriberIfMissing(create bool) Option {
+	return func(c *Client) {
+		c.createSubscriber = &create
+	}
+}
+
+// WithRegion pins the client to a data region. Requests carry an
+// X-Data-Region header and, when the server URL has a DNS host name, go to
+// the regional host: the host prefixed with the region (api.example.com
+// becomes eu.api.example.com). IP addresses and single-label hosts such as
+// localhost, typical of self-hosted servers, are left as they are. The server
+// rejects requests for resources stored in another region with
+// 421 Misdirected Request; see IsWrongRegion. An empty region leaves the
+// client as it is.
+func WithRegion(region string) Option {
+	return func(c *Client) {
+		if region == "" {
+			return
+		}
+		c.region = region
+		u, err := url.Parse(c.baseURL)
+		if err != nil || !isDNSName(u.Hostname()) || strings.HasPrefix(u.Host, region+".") {
+			return
+		}
+		u.Host = region + "." + u.Host
+		c.baseURL = u.String()
+	}
+}
+
+// isDNSName reports whether host is a dotted DNS name rather than an IP
+// address or a single label such as localhost.
+func isDNSName(host string) bool {
+	return strings.Contains(host, ".") && net.ParseIP(host) == nil
+}
+
+// WithMaxConcurrency caps the number of requests in flight at once. Further
+// calls wait for a free slot or for their context to be done. An n of 0 or
+// less means no limit.
+func WithMaxConcurrency(n int) Option {
+	return func(c *Client) {
+		if n <= 0 {
+			c.sem = nil
+			return
+		}
+		c.sem = make(chan struct{}, n)
+	}
+}
+
+// FieldNaming controls how the keys given to WithExtraFields are sent.
+type FieldNaming int
+
+const (
+	// FieldNamesAsIs sends keys exactly as given. This is the default.
+	FieldNamesAsIs FieldNaming = iota
+	// NormalizeSnakeCase converts keys to the API's snake_case, so
+	// "promoCode" and "PromoCode" are both sent as "promo_code".
+	NormalizeSnakeCase
+)
+
+func WithExtraFieldNaming(naming FieldNaming) Option {
+	return func(c *Client) {
+		c.fieldNaming = naming
+	}
+}
+
+// WithCircuitBreaker fails calls fast with ErrCircuitOpen after
+// failureThreshold consecutive requests fail with a network error or a 5xx
+// response. After cooldown one probe request is let through: success closes
+// the circuit, failure keeps it open for another cooldown.
+func WithCircuitBreaker(failureThreshold int, cooldown time.Duration) Option {
+	return func(c *Client) {
+		c.breaker = &breaker{threshold: failureThreshold, cooldown: cooldown}
+	}
+}
+
+// WithOfferingsCache caches GetCurrentOffering results per app. Entries older
+// than ttl are refreshed in the background while the cached value is served.
+func WithOfferingsCache(ttl time.Duration) Option {
+	return func(c *Client) {
+		c.offerings = &offeringsCache{ttl: ttl, entries: map[string]*offeringsEntry{}}
+	}
+}
+
+// WithMetadataCache caches GetProduct, ListProducts, and ListEntitlements
+// results per app for ttl, keeping the most recently used apps. Calls made
+// with call options always go to the server. Use Client.InvalidateMetadata
+// after changing products or entitlements elsewhere.
+func WithMetadataCache(ttl time.Duration) Option {
+	return func(c *Client) {
+		c.metadata = newMetadataCache(ttl)
+	}
+}
+
+// WithStaleOnError makes GetSubscriber remember the last state it fetched
+// for each subscriber and return it, with Stale set, when a later fetch fails
+// with a server error, a timeout, or a network error, as long as that state
+// is at most maxStale old. Client errors such as 404 are always returned.
+// Calls made with options that change the response, such as WithAsOf or
+// WithFields, neither use nor update the remembered state; options such as
+// WithCallHeaders do not affect it.
+func WithStaleOnError(maxStale time.Duration) Option {
+	return func(c *Client) {
+		c.stale = newStaleCache(maxStale)
+	}
+}
+
+// WithWebhookKeyTTL sets how long Client.VerifyWebhookSignature caches the
+// server's webhook signing keys.
+func WithWebhookKeyTTL(ttl time.Duration) Option {
+	return func(c *Client) {
+		c.webhookKeys.ttl = ttl
+	}
+}
+
+// WithEntitlementTokenCache caches GetEntitlementToken results per
+// subscriber and refreshes each token in the background once refreshAt (a
+// fraction between 0 and 1, default 0.8) of its lifetime has passed, so
+// callers are served a valid token without waiting on the network. A token
+// not read since its last refresh is left to expire, the least recently used
+// subscribers are evicted once 4096 are cached, and Client.Close stops all
+// refreshing.
+func WithEntitlementTokenCache(refreshAt float64) Option {
+	return func(c *Client) {
+		if refreshAt <= 0 || refreshAt >= 1 {
+			refreshAt = defaultTokenRefreshAt
+		}
+		c.tokens = newTokenCache(refreshAt)
+	}
+}
+
+// WithIncompatibleServerHook sets a function called by ServerInfo when the
+// server's API version is not compatible with APIVersion.
+func WithIncompatibleServerHook(fn func(*ServerInfo)) Option {
+	return func(c *Client) {
+		c.onIncompatible = fn
+	}
+}
+
+// WithAdaptiveThrottle paces requests by the X-RateLimit-Remaining and
+// X-RateLimit-Reset headers of earlier responses: when the budget runs low,
+// requests are spread over the rest of the window, and when it is spent they
+// wait for the reset. Waiting stops early if the context is done.
+func WithAdaptiveThrottle() Option {
+	return func(c *Client) {
+		c.throttle = &throttle{}
+	}
+}
+
+// WithPinnedCertificates makes the client refuse TLS connections unless the
+// server's certificate has one of the given SHA-256 fingerprints, written in
+// hex with or without colons. The check is added to the client's transport,
+// including one supplied by another option. An *http.Transport is checked
+// during the TLS handshake; any other RoundTripper is wrapped and checked
+// when each response arrives, after the request has been sent, so prefer an
+// *http.Transport where the request itself must not reach an unpinned
+// server.
+func WithPinnedCertificates(fingerprints ...string) Option {
+	return func(c *Client) {
+		c.pins = append(c.pins, fingerprints...)
+	}
+}
+
+// WithAutoIdempotency attaches an Idempotency-Key to every POST, PUT, PATCH
+// and DELETE, derived from the method, URL and body. Repeating a call with
+// the same arguments therefore reuses its key, and the server applies it
+// only once; to make two identical calls count separately, give each its
+// own key with WithIdempotencyKey.
+func WithAutoIdempotency() Option {
+	return func(c *Client) {
+		c.autoIdempotency = true
+	}
+}
+
+// WithPathTimeout sets the timeout for requests whose path matches pattern,
+// in place of the http.Client timeout. A pattern matches a path that starts
+// with its segments, and a "*" segment matches any one segment, so
+// "/v1/apps/*/transactions/export" covers exports of every app. When several
+// patterns match, the longest wins. The timeout applies to each attempt.
+func WithPathTimeout(pattern string, d time.Duration) Option {
+	return func(c *Client) {
+		if c.pathTimeouts == nil {
+			c.pathTimeouts = map[string]time.Duration{}
+		}
+		c.pathTimeouts[pattern] = d
+	}
+}
+
+// WithAuditLogger sends a record of every mutating call to logger. See
+// AuditLogger.
+func WithAuditLogger(logger AuditLogger) Option {
+	return func(c *Client) {
+		c.auditLogger = logger
+	}
+}
+
+// WithDeadlineHeader sends the time left before the context deadline as an
+// X-Request-Timeout-Ms header, so the server can abandon work the caller
+// will not wait for.
+func WithDeadlineHeader() Option {
+	return func(c *Client) {
+		c.sendDeadline = true
+	}
+}
+
+// WithMethodOverride sends PATCH and DELETE requests as POST with an
+// X-HTTP-Method-Override header, for networks whose proxies block those
+// methods. The server must be configured to honor the header.
+func WithMethodOverride() Option {
+	return func(c *Client) {
+		c.methodOverride = true
+	}
+}
+
+// WithCaptureRaw keeps the raw response body of calls made with
+// WithResponse in Response.Raw.
+func WithCaptureRaw() Option {
+	return func(c *Client) {
+		c.captureRaw = true
+	}
+}
+
+type callOptions struct {
+	query    url.Values
+	headers  http.Header
+	fields   map[string]any
+	extra    map[string]any
+	reveal   bool
+	upsert   bool
+	validate bool
+	response *Response
+	timeout  time.Duration
+	audit    *auditInfo
+}
+
+// CallOption customizes a single method call.
+type CallOption func(*callOptions)
+
+// ListOption is a CallOption for paginated list calls. Pages are walked by
+// passing the ID of the last item received to WithCursor.
+type ListOption = CallOption
+
+func (co *callOptions) setCreated() {
+	if co.response != nil {
+		co.response.Created = true
+	}
+}
+
+// setExisting records that an upsert found the resource rather than
+// creating it.
+func (co *callOptions) setExisting() {
+	if co.response != nil {
+		*co.response = Response{}
+	}
+}
+
+func newCallOptions(opts []CallOption) *callOptions {
+	co := &callOptions{query: url.Values{}, headers: http.Header{}, fields: map[string]any{}, extra: map[string]any{}}
+	for _, opt := range opts {
+		opt(co)
+	}
+	return co
+}
+
+// lookupOptions returns the options for the reads a write makes on the
+// caller's behalf, such as the lookup of WithUpsert: the call's headers other
+// than its Idempotency-Key. Query, body, and response options belong to the
+// write alone.
+func (co *callOptions) lookupOptions() []CallOption {
+	headers := co.headers.Clone()
+	headers.Del(idempotencyKeyHeader)
+	if len(headers) == 0 {
+		return nil
+	}
+	return []CallOption{WithCallHeaders(headers)}
+}
+
+// WithCallHeaders sets headers for a single call. They are merged with the
+// client-wide headers and take precedence over them.
+func WithCallHeaders(h http.Header) CallOption {
+	return func(co *callOptions) {
+		for k, v := range h {
+			co.headers[http.CanonicalHeaderKey(k)] = v
+		}
+	}
+}
+
+// WithIdempotencyKey sends key as the call's Idempotency-Key, so the server
+// applies a repeated call only once. Use a new random key, such as a UUID,
+// per logical operation. Every attempt made by WithRetry sends the same key.
+// It overrides the key WithAutoIdempotency would derive.
+func WithIdempotencyKey(key string) CallOption {
+	return func(co *callOptions) {
+		co.headers.Set(idempotencyKeyHeader, key)
+	}
+}
+
+// WithResponse fills resp with details of the HTTP response to the call: its
+// status, request ID, and rate-limit state. It is filled for failed calls
+// too, as long as a response arrived.
+func WithResponse(resp *Response) CallOption {
+	return func(co *callOptions) {
+		co.response = resp
+	}
+}
+
+// WithExtraFields adds fields the SDK does not model to the JSON body of the
+// call. Fields the method itself sets take precedence. Keys are sent as given
+// unless the client was created with WithExtraFieldNaming(NormalizeSnakeCase).
+// GET calls send no body, so they ignore the fields.
+func WithExtraFields(fields map[string]any) CallOption {
+	return func(co *callOptions) {
+		for k, v := range fields {
+			co.extra[k] = v
+		}
+	}
+}
+
+// WithCreateSubscriberIfMissing controls whether a receipt submission for an
+// unknown app user ID creates the subscriber. The server creates it by
+// default; with create set to false the submission fails with a 404 *Error
+// instead, which catches mistyped IDs. It overrides the client's
+// WithDefaultCreateSubscriberIfMissing.
+func WithCreateSubscriberIfMissing(create bool) CallOption {
+	return func(co *callOptions) {
+		co.fields["create_subscriber"] = create
+	}
+}
+
+// WithUpsert makes CreateEntitlement and CreateProduct return the existing
+// resource with the same name or store product ID instead of failing with a
+// conflict. Pass WithResponse to learn whether the resource was created.
+// The lookup of the existing resource carries the call's headers but none of
+// its other options, so DryRun and body fields only affect the create.
+func WithUpsert() CallOption {
+	return func(co *callOptions) {
+		co.upsert = true
+	}
+}
+
+// WithValidateName makes CreateEntitlement and UpdateEntitlement check the
+// entitlement name with ValidateEntitlementName before sending it.
+func WithValidateName() CallOption {
+	return func(co *callOptions) {
+		co.validate = true
+	}
+}
+
+// DryRun asks the server to validate and compute the result of a mutating
+// call without applying it.
+func DryRun() CallOption {
+	return func(co *callOptions) {
+		co.query.Set("dry_run", "true")
+	}
+}
+
+// WithConsumer names the event consumer whose position AckEvents and
+// AckThrough advance.
+func WithConsumer(name string) CallOption {
+	return func(co *callOptions) {
+		co.query.Set("consumer", name)
+	}
+}
+
+// WithSubscriberCounts makes ListProducts fill in each product's
+// ActiveSubscriberCount.
+func WithSubscriberCounts() ListOption {
+	return func(co *callOptions) {
+		co.query.Set("include", "subscriber_counts")
+	}
+}
+
+// WithFields asks ListEvents to return only the named fields of each event,
+// such as "id", "subscriber_id" and "event_type". The other fields of the
+// returned events are left empty; GetEvent fetches an event in full.
+func WithFields(fields ...string) ListOption {
+	return func(co *callOptions) {
+		co.query.Set("fields", strings.Join(fields, ","))
+	}
+}
+
+func WithLimit(n int) ListOption {
+	return func(co *callOptions) {
+		co.query.Set("limit", strconv.Itoa(n))
+	}
+}
+
+func WithCursor(cursor string) ListOption {
+	return func(co *callOptions) {
+		co.query.Set("cursor", cursor)
+	}
+}
+
+func setDateRange(q url.Values, from, to time.Time) {
+	if !from.IsZero() {
+		q.Set("from", from.UTC().Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		q.Set("to", to.UTC().Format(time.RFC3339))
+	}
+}
+
+type Order string
+
+const (
+	OrderAsc  Order = "asc"
+	OrderDesc Order = "desc"
+)
+
+func WithOrder(order Order) ListOption {
+	return func(co *callOptions) {
+		co.query.Set("order", string(order))
+	}
+}
+
+func WithAsOf(t time.Time) ListOption {
+	return func(co *callOptions) {
+		co.query.Set("as_of", t.UTC().Format(time.RFC3339))
+	}
+}
+
+// WithDeliveryStatus limits ListWebhookDeliveries to deliveries in any of
+// the given statuses.
+func WithDeliveryStatus(statuses ...DeliveryStatus) ListOption {
+	return func(co *callOptions) {
+		names := make([]string, len(statuses))
+		for i, s := range statuses {
+			names[i] = string(s)
+		}
+		co.query.Set("status", strings.Join(names, ","))
+	}
+}
+
+// WithEventType limits ListEvents to events of any of the given types.
+func WithEventType(types ...EventType) ListOption {
+	return func(co *callOptions) {
+		names := make([]string, len(types))
+		for i, t := range types {
+			names[i] = string(t)
+		}
+		co.query.Set("event_type", strings.Join(names, ","))
+	}
+}
+
+// WithTransactionStatus limits ListTransactions to transactions in any of
+// the given statuses, such as "active" or "refunded".
+func WithTransactionStatus(statuses ...string) ListOption {
+	return func(co *callOptions) {
+		co.query.Set("status", strings.Join(statuses, ","))
+	}
+}
+
+// WithStore limits ListTransactions to transactions from any of the given
+// stores.
+func WithStore(stores ...Store) ListOption {
+	return func(co *callOptions) {
+		names := make([]string, len(stores))
+		for i, s := range stores {
+			names[i] = string(s)
+		}
+		co.query.Set("store", strings.Join(names, ","))
+	}
+}
+
+// WithSubscriberID limits ListEvents to the events of one subscriber, by
+// its OpenCat subscriber ID.
+func WithSubscriberID(subscriberID string) ListOption {
+	return func(co *callOptions) {
+		co.query.Set("subscriber_id", subscriberID)
+	}
+}
+
+// WithDateRange limits a list to items created between from and to. A zero
+// from or to leaves that end open.
+func WithDateRange(from, to time.Time) ListOption {
+	return func(co *callOptions) {
+		setDateRange(co.query, from, to)
+	}
+}
+
+// WithReveal makes ListWebhooks return endpoint secrets, which it otherwise
+// omits. Prefer GetWebhookSecret when only one secret is needed.
+func WithReveal() CallOption {
+	return func(co *callOptions) {
+		co.reveal = true
+	}
+}
+
+// WithAutoDisableThreshold makes CreateWebhook disable the endpoint after n
+// consecutive failed deliveries.
+func WithAutoDisableThreshold(n int) CallOption {
+	return func(co *callOptions) {
+		co.fields["auto_disable_threshold"] = n
+	}
+}
+
+// WithCustomHeaders makes CreateWebhook send headers with every delivery to
+// the endpoint, e.g. to authenticate with a gateway in front of it.
+func WithCustomHeaders(headers map[string]string) CallOption {
+	return func(co *callOptions) {
+		co.fields["custom_headers"] = headers
+	}
+}
+
+// WithSigningAlgorithm makes CreateWebhook sign deliveries to the endpoint
+// with alg instead of the server default.
+func WithSigningAlgorithm(alg SigningAlgorithm) CallOption {
+	return func(co *callOptions) {
+		co.fields["signing_algorithm"] = alg
+	}
+}
+
+func snakeCase(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			prevLower := i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]))
+			nextLower := i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])
+			if prevLower || nextLower {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		} else if r == '-' || r == ' ' {
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,86 @@
+package opencat
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// pinCertificates returns a copy of hc whose transport rejects TLS
+// connections unless the server's leaf certificate has one of the given
+// SHA-256 fingerprints. Only the leaf is checked: other certificates in the
+// chain are not proven to belong to the server.
+//
+// An *http.Transport is checked during the handshake, before the request is
+// sent. Any other RoundTripper is wrapped and checked by the TLS state of
+// each response, so a request to an unpinned server is sent but its response
+// is discarded.
+func pinCertificates(hc *http.Client, fingerprints []string) *http.Client {
+	pins := make(map[string]bool, len(fingerprints))
+	for _, fp := range fingerprints {
+		pins[strings.ToLower(strings.ReplaceAll(fp, ":", ""))] = true
+	}
+
+	pinned := *hc
+	base := hc.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t, ok := base.(*http.Transport)
+	if !ok {
+		pinned.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			resp, err := base.RoundTrip(r)
+			if err != nil || r.URL.Scheme != "https" {
+				return resp, err
+			}
+			if resp.TLS == nil {
+				err = errors.New("opencat: transport reported no TLS state to check pinned certificates against")
+			} else {
+				err = checkPins(pins, *resp.TLS)
+			}
+			if err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+			return resp, nil
+		})
+		return &pinned
+	}
+
+	t = t.Clone()
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	verify := t.TLSClientConfig.VerifyConnection
+	t.TLSClientConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+		if verify != nil {
+			if err := verify(cs); err != nil {
+				return err
+			}
+		}
+		return checkPins(pins, cs)
+	}
+	pinned.Transport = t
+	return &pinned
+}
+
+func checkPins(pins map[string]bool, cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("opencat: server presented no certificate")
+	}
+	sum := sha256.Sum256(cs.PeerCertificates[0].Raw)
+	if fp := hex.EncodeToString(sum[:]); !pins[fp] {
+		return fmt.Errorf("opencat: server certificate %s does not match a pinned fingerprint", fp)
+	}
+	return nil
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,57 @@
+package opencat
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPinnedCertificates(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]App{})
+	}))
+	defer srv.Close()
+
+	sum := sha256.Sum256(srv.Certificate().Raw)
+	fp := strings.ToUpper(hex.EncodeToString(sum[:]))
+	trustTestServer := func(c *Client) { c.httpClient = srv.Client() }
+
+	c := NewClient(srv.URL, "test-key", trustTestServer, WithPinnedCertificates("00:11", fp))
+	if _, err := c.ListApps(); err != nil {
+		t.Fatalf("expected pinned certificate to be accepted: %v", err)
+	}
+
+	c = NewClient(srv.URL, "test-key", trustTestServer, WithPinnedCertificates(strings.Repeat("ab", 32)))
+	if _, err := c.ListApps(); err == nil || !strings.Contains(err.Error(), "pinned fingerprint") {
+		t.Fatalf("expected pin mismatch, got %v", err)
+	}
+}
+
+func TestPinnedCertificatesWithCustomRoundTripper(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]App{})
+	}))
+	defer srv.Close()
+
+	sum := sha256.Sum256(srv.Certificate().Raw)
+	fp := hex.EncodeToString(sum[:])
+	var wrapped int
+	custom := &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		wrapped++
+		return srv.Client().Transport.RoundTrip(r)
+	})}
+
+	c := NewClient(srv.URL, "test-key", WithHTTPClient(custom), WithPinnedCertificates(fp))
+	if _, err := c.ListApps(); err != nil || wrapped != 1 {
+		t.Fatalf("expected the custom transport to be used and the pin accepted, got %v after %d calls", err, wrapped)
+	}
+
+	c = NewClient(srv.URL, "test-key", WithHTTPClient(custom), WithPinnedCertificates(strings.Repeat("ab", 32)))
+	if _, err := c.ListApps(); err == nil || !strings.Contains(err.Error(), "pinned fingerprint") {
+		t.Fatalf("expected pin mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package opencat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Receipt is a store purchase to submit with SubmitReceipt. Each store needs
+// different details to verify a purchase; build a Receipt with AppleReceipt,
+// GoogleReceipt, StripeReceipt or AmazonReceipt.
+type Receipt interface {
+	Store() Store
+	// body returns the store-specific fields of the submission, or an error
+	// naming the first missing one.
+	body() (map[string]string, error)
+}
+
+type appleReceipt struct {
+	data string
+}
+
+// AppleReceipt is an App Store receipt, given as the base64 receipt data or
+// a signed transaction.
+func AppleReceipt(data string) Receipt {
+	return appleReceipt{data: data}
+}
+
+func (appleReceipt) Store() Store { return StoreApple }
+
+func (r appleReceipt) body() (map[string]string, error) {
+	if r.data == "" {
+		return nil, missingReceiptField(StoreApple, "receipt data")
+	}
+	return map[string]string{"receipt_data": r.data}, nil
+}
+
+type googleReceipt struct {
+	packageName, token, productID string
+}
+
+// GoogleReceipt is a Google Play purchase: the app's package name, the
+// purchase token, and the Play product ID it was bought for.
+func GoogleReceipt(packageName, purchaseToken, productID string) Receipt {
+	return googleReceipt{packageName: packageName, token: purchaseToken, productID: productID}
+}
+
+func (googleReceipt) Store() Store { return StoreGoogle }
+
+func (r googleReceipt) body() (map[string]string, error) {
+	switch {
+	case r.packageName == "":
+		return nil, missingReceiptField(StoreGoogle, "package name")
+	case r.token == "":
+		return nil, missingReceiptField(StoreGoogle, "purchase token")
+	case r.productID == "":
+		return nil, missingReceiptField(StoreGoogle, "product ID")
+	}
+	return map[string]string{
+		"receipt_data":     r.token,
+		"package_name":     r.packageName,
+		"store_product_id": r.productID,
+	}, nil
+}
+
+type stripeReceipt struct {
+	id string
+}
+
+// StripeReceipt is a Stripe purchase, given as the ID of its subscription
+// (sub_...) or Checkout session (cs_...).
+func StripeReceipt(subscriptionID string) Receipt {
+	return stripeReceipt{id: subscriptionID}
+}
+
+func (stripeReceipt) Store() Store { return StoreStripe }
+
+func (r stripeReceipt) body() (map[string]string, error) {
+	if r.id == "" {
+		return nil, missingReceiptField(StoreStripe, "subscription ID")
+	}
+	if !strings.HasPrefix(r.id, "sub_") && !strings.HasPrefix(r.id, "cs_") {
+		return nil, fmt.Errorf("opencat: stripe receipt: %q is not a subscription or checkout session ID", r.id)
+	}
+	return map[string]string{"receipt_data": r.id}, nil
+}
+
+type amazonReceipt struct {
+	userID, receiptID string
+}
+
+// AmazonReceipt is an Amazon Appstore purchase. The Receipt Verification
+// Service needs the Amazon user ID as well as the receipt ID.
+func AmazonReceipt(userID, receiptID string) Receipt {
+	return amazonReceipt{userID: userID, receiptID: receiptID}
+}
+
+func (amazonReceipt) Store() Store { return StoreAmazon }
+
+func (r amazonReceipt) body() (map[string]string, error) {
+	switch {
+	case r.userID == "":
+		return nil, missingReceiptField(StoreAmazon, "user ID")
+	case r.receiptID == "":
+		return nil, missingReceiptField(StoreAmazon, "receipt ID")
+	}
+	return map[string]string{"receipt_data": r.receiptID, "amazon_user_id": r.userID}, nil
+}
+
+// receiptBody is the request body for receipt, with its store, or an error
+// if receipt is nil or incomplete.
+func receiptBody(appID string, receipt Receipt) (map[string]string, error) {
+	if receipt == nil {
+		return nil, errors.New("opencat: receipt is required")
+	}
+	body, err := receipt.body()
+	if err != nil {
+		return nil, err
+	}
+	body["app_id"] = appID
+	body["store"] = string(receipt.Store())
+	return body, nil
+}
+
+func missingReceiptField(store Store, field string) error {
+	return fmt.Errorf("opencat: %s receipt: %s is required", store, field)
+}
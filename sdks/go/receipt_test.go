@@ -0,0 +1,67 @@
+package opencat
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSubmitReceiptBodies(t *testing.T) {
+	var body map[string]string
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"id":"tx1"}`))
+	})
+	defer srv.Close()
+
+	tests := []struct {
+		receipt Receipt
+		want    map[string]string
+	}{
+		{AppleReceipt("data"), map[string]string{"store": "apple", "receipt_data": "data"}},
+		{GoogleReceipt("com.example", "tok", "pro_monthly"), map[string]string{
+			"store": "google", "receipt_data": "tok", "package_name": "com.example", "store_product_id": "pro_monthly",
+		}},
+		{StripeReceipt("sub_123"), map[string]string{"store": "stripe", "receipt_data": "sub_123"}},
+		{StripeReceipt("cs_456"), map[string]string{"store": "stripe", "receipt_data": "cs_456"}},
+		{AmazonReceipt("amzn-user", "rcpt"), map[string]string{"store": "amazon", "receipt_data": "rcpt", "amazon_user_id": "amzn-user"}},
+	}
+	for _, tt := range tests {
+		if _, err := c.SubmitReceipt("app-1", "user-1", "p1", tt.receipt); err != nil {
+			t.Fatal(err)
+		}
+		if body["app_id"] != "app-1" || body["app_user_id"] != "user-1" || body["product_id"] != "p1" {
+			t.Fatalf("unexpected body %v", body)
+		}
+		for k, v := range tt.want {
+			if body[k] != v {
+				t.Fatalf("%s: expected %s=%q, got body %v", tt.receipt.Store(), k, v, body)
+			}
+		}
+	}
+}
+
+func TestReceiptValidation(t *testing.T) {
+	tests := []struct {
+		receipt Receipt
+		want    string
+	}{
+		{AppleReceipt(""), "apple receipt: receipt data is required"},
+		{GoogleReceipt("", "tok", "pro"), "google receipt: package name is required"},
+		{GoogleReceipt("com.example", "", "pro"), "google receipt: purchase token is required"},
+		{GoogleReceipt("com.example", "tok", ""), "google receipt: product ID is required"},
+		{StripeReceipt(""), "stripe receipt: subscription ID is required"},
+		{StripeReceipt("pi_123"), "not a subscription or checkout session ID"},
+		{AmazonReceipt("", "rcpt"), "amazon receipt: user ID is required"},
+		{AmazonReceipt("amzn-user", ""), "amazon receipt: receipt ID is required"},
+	}
+	c := NewClient("http://unused", "test-key")
+	for _, tt := range tests {
+		_, err := c.SubmitReceipt("app-1", "user-1", "p1", tt.receipt)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("expected error containing %q, got %v", tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package opencat
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+}
+
+// backoff is the delay before retrying after the given attempt: the base
+// delay doubled per attempt, plus up to half again of random jitter so
+// clients that failed together do not retry together.
+func (p *retryPolicy) backoff(attempt int) time.Duration {
+	d := p.baseDelay << (attempt - 1)
+	if d > 1 {
+		d += time.Duration(rand.Int63n(int64(d/2) + 1))
+	}
+	return d
+}
+
+// withRetry runs fn until it succeeds, fails permanently, or the policy is
+// exhausted. Only idempotent requests are retried: GET and HEAD, and others
+// sent with an Idempotency-Key. A 429 with a Retry-After header waits as long
+// as the server asks instead of backing off. The context deadline is a budget
+// for the whole call: a sleep that would run past it is skipped and the last
+// error is returned.
+func (c *Client) withRetry(ctx context.Context, idempotent bool, fn func() error) error {
+	if c.retry == nil || !idempotent {
+		return fn()
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= c.retry.maxAttempts || !isTransient(ctx, err) {
+			return err
+		}
+
+		delay := c.retry.backoff(attempt)
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests && apiErr.retryAfter > 0 {
+			delay = apiErr.retryAfter
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// isTransient reports whether err is worth retrying: a 429 or 5xx response,
+// or a failure to send the request or receive its response. Errors decoding
+// a response that did arrive are returned as they are.
+func isTransient(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == 429 || apiErr.StatusCode >= 500
+	}
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr)
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date, returning 0 if it is absent or invalid.
+func parseRetryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
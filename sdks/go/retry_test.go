@@ -0,0 +1,204 @@
+package opencat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryRecoversFromTransientError(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(503)
+			return
+		}
+		json.NewEncoder(w).Encode([]App{{ID: "app-1"}})
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithRetry(3, time.Millisecond))
+	apps, err := c.ListApps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apps) != 1 || calls != 3 {
+		t.Fatalf("expected 1 app after 3 calls, got %d apps after %d calls", len(apps), calls)
+	}
+}
+
+func TestRetrySkipsNonIdempotentRequests(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(503)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithRetry(3, time.Millisecond))
+	if _, err := c.CreateApp("A", "ios", "com.a"); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 call, got %d", calls)
+	}
+}
+
+func TestRetryIdempotencyKeyedRequests(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get("Idempotency-Key"); key != "k1" {
+			t.Errorf("expected every attempt to send key k1, got %q", key)
+		}
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(502)
+			return
+		}
+		w.Write([]byte(`{"id":"tx-1"}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithRetry(3, time.Millisecond))
+	if _, err := c.SubmitReceipt("app-1", "user-1", "p1", AppleReceipt("data"), WithIdempotencyKey("k1")); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestRetryStopsOnClientError(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(400)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithRetry(3, time.Millisecond))
+	if _, err := c.ListApps(); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 call, got %d", calls)
+	}
+}
+
+func TestRetryStopsOnMalformedResponse(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(`[{"id":`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithRetry(3, time.Millisecond))
+	if _, err := c.ListApps(); err == nil {
+		t.Fatal("expected a decode error")
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 call, got %d", calls)
+	}
+}
+
+func TestRetryRecoversFromDroppedConnection(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithRetry(3, time.Millisecond))
+	if _, err := c.ListApps(); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", calls)
+	}
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(429)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithRetry(3, time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := c.ListAppsContext(ctx); !hasStatus(err, 429) {
+		t.Fatalf("expected the 429, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected no retry within a budget shorter than Retry-After, got %d calls", calls)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	for _, tc := range []struct {
+		value    string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"3", 3 * time.Second, 3 * time.Second},
+		{"soon", 0, 0},
+		{date, 58 * time.Second, time.Minute},
+	} {
+		got := parseRetryAfter(http.Header{"Retry-After": {tc.value}})
+		if got < tc.min || got > tc.max {
+			t.Errorf("parseRetryAfter(%q) = %s, want between %s and %s", tc.value, got, tc.min, tc.max)
+		}
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	p := &retryPolicy{baseDelay: 100 * time.Millisecond}
+	for i := 0; i < 50; i++ {
+		if d := p.backoff(2); d < 200*time.Millisecond || d > 300*time.Millisecond {
+			t.Fatalf("backoff(2) = %s, want between 200ms and 300ms", d)
+		}
+	}
+}
+
+func TestRetryRespectsDeadlineBudget(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(503)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithRetry(10, 40*time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.ListAppsContext(ctx)
+	elapsed := time.Since(start)
+
+	var apiErr *Error
+	ok := errors.As(err, &apiErr)
+	if !ok || apiErr.StatusCode != 503 {
+		t.Fatalf("expected last *Error 503, got %v", err)
+	}
+	if elapsed >= 100*time.Millisecond {
+		t.Fatalf("expected to return before the deadline, took %s", elapsed)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 attempts within budget, got %d", calls)
+	}
+}
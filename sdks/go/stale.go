@@ -0,0 +1,84 @@
+package opencat
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Subscribers whose last good state a client keeps for WithStaleOnError; the
+// least recently fetched is dropped beyond this.
+const staleCacheSize = 10000
+
+type staleCache struct {
+	maxStale time.Duration
+	max      int
+
+	mu    sync.Mutex
+	lru   *list.List
+	users map[string]*list.Element
+}
+
+type staleEntry struct {
+	appUserID string
+	info      SubscriberInfo
+	fetchedAt time.Time
+}
+
+func newStaleCache(maxStale time.Duration) *staleCache {
+	return &staleCache{maxStale: maxStale, max: staleCacheSize, lru: list.New(), users: map[string]*list.Element{}}
+}
+
+func (sc *staleCache) store(appUserID string, info *SubscriberInfo) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	entry := &staleEntry{appUserID: appUserID, info: *info, fetchedAt: time.Now()}
+	if el, ok := sc.users[appUserID]; ok {
+		el.Value = entry
+		sc.lru.MoveToFront(el)
+		return
+	}
+	sc.users[appUserID] = sc.lru.PushFront(entry)
+	for sc.lru.Len() > sc.max {
+		oldest := sc.lru.Back()
+		sc.lru.Remove(oldest)
+		delete(sc.users, oldest.Value.(*staleEntry).appUserID)
+	}
+}
+
+// load returns a copy of the last good state of appUserID, marked Stale, if
+// it is at most maxStale old.
+func (sc *staleCache) load(appUserID string) (*SubscriberInfo, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	el, ok := sc.users[appUserID]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*staleEntry)
+	if time.Since(entry.fetchedAt) > sc.maxStale {
+		return nil, false
+	}
+	info := entry.info
+	info.Stale = true
+	return &info, true
+}
+
+// changesResponse reports whether opts may make the server return something
+// other than the subscriber's plain current state, which is all the stale
+// cache holds. Only query parameters and WithReveal change what is returned;
+// headers, body fields, and WithResponse do not.
+func changesResponse(opts []CallOption) bool {
+	co := newCallOptions(opts)
+	return len(co.query) > 0 || co.reveal
+}
+
+// serveStale reports whether a failed fetch may fall back to stale state:
+// server errors, timeouts, and network failures may, while client errors and
+// calls canceled by the caller may not.
+func serveStale(err error) bool {
+	var clientErr *ClientError
+	return !errors.Is(err, context.Canceled) && !errors.As(err, &clientErr)
+}
@@ -0,0 +1,58 @@
+package opencat
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStaleOnError(t *testing.T) {
+	status := http.StatusOK
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte(`{"subscriber":{"app_user_id":"user-1"},"active_entitlements":[{"id":"pro","is_active":true}]}`))
+		}
+	})
+	defer srv.Close()
+	WithStaleOnError(50 * time.Millisecond)(c)
+
+	info, err := c.GetSubscriber("user-1")
+	if err != nil || info.Stale {
+		t.Fatalf("expected a fresh result, got %+v, %v", info, err)
+	}
+
+	status = http.StatusServiceUnavailable
+	info, err = c.GetSubscriber("user-1")
+	if err != nil || !info.Stale || info.ActiveEntitlements[0].ID != "pro" {
+		t.Fatalf("expected the last good state marked stale, got %+v, %v", info, err)
+	}
+	info, err = c.GetSubscriber("user-1", WithCallHeaders(http.Header{"X-Trace": {"t1"}}))
+	if err != nil || !info.Stale {
+		t.Fatalf("expected headers not to bypass the fallback, got %+v, %v", info, err)
+	}
+	if _, err := c.GetSubscriber("user-1", WithAsOf(time.Now())); !hasStatus(err, http.StatusServiceUnavailable) {
+		t.Fatalf("expected WithAsOf to bypass the fallback, got %v", err)
+	}
+	if _, err := c.GetSubscriber("user-2"); !hasStatus(err, http.StatusServiceUnavailable) {
+		t.Fatalf("expected the error for a subscriber never fetched, got %v", err)
+	}
+
+	status = http.StatusNotFound
+	if _, err := c.GetSubscriber("user-1"); !hasStatus(err, http.StatusNotFound) {
+		t.Fatalf("expected client errors to be returned, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.GetSubscriberContext(ctx, "user-1"); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	status = http.StatusBadGateway
+	time.Sleep(60 * time.Millisecond)
+	if _, err := c.GetSubscriber("user-1"); !hasStatus(err, http.StatusBadGateway) {
+		t.Fatalf("expected state older than maxStale not to be served, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package opencat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// StreamSubscribers exports every subscriber of an app in a single response
+// of newline-delimited JSON, one Subscriber per line. The caller must close
+// the returned body; NewSubscriberDecoder reads it one subscriber at a time.
+// The client's timeout does not apply to the stream, which runs until it is
+// read to the end, closed, or ctx is done. The stream goes through the
+// circuit breaker and throttle like any request and holds its
+// WithMaxConcurrency slot until the body is closed.
+func (c *Client) StreamSubscribers(ctx context.Context, appID string, opts ...CallOption) (io.ReadCloser, error) {
+	co := newCallOptions(opts)
+	u := c.requestURL(fmt.Sprintf("/v1/apps/%s/subscribers/export", appID), nil, co)
+	req, err := c.newRequest(ctx, "GET", u, nil, co.headers)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/x-ndjson")
+	hc := *c.httpClient
+	hc.Timeout = 0
+
+	if err := c.admit(ctx); err != nil {
+		return nil, err
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		c.release()
+		c.recordOutcome(ctx, err, 0)
+		return nil, contextError(ctx, err)
+	}
+	c.observe(ctx, resp, nil)
+	if resp.StatusCode >= 400 {
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		c.release()
+		return nil, classifyError(newError(resp, data))
+	}
+	return &streamBody{ReadCloser: resp.Body, release: c.release}, nil
+}
+
+// streamBody holds the client's concurrency slot until the stream is closed.
+type streamBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *streamBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// SubscriberDecoder reads the subscribers of a StreamSubscribers body.
+type SubscriberDecoder struct {
+	body io.ReadCloser
+	dec  *json.Decoder
+}
+
+func NewSubscriberDecoder(body io.ReadCloser) *SubscriberDecoder {
+	return &SubscriberDecoder{body: body, dec: json.NewDecoder(body)}
+}
+
+// Next returns the next subscriber, or io.EOF once the stream has ended.
+func (d *SubscriberDecoder) Next() (*Subscriber, error) {
+	var s Subscriber
+	if err := d.dec.Decode(&s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Close closes the underlying body.
+func (d *SubscriberDecoder) Close() error {
+	return d.body.Close()
+}
@@ -0,0 +1,123 @@
+package opencat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStreamSubscribers(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/apps/app-1/subscribers/export" || r.Header.Get("Accept") != "application/x-ndjson" {
+			t.Fatalf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(w, "{\"id\":\"s%d\",\"app_id\":\"app-1\",\"app_user_id\":\"user-%d\"}\n", i, i)
+			w.(http.Flusher).Flush()
+		}
+	})
+	defer srv.Close()
+
+	body, err := c.StreamSubscribers(context.Background(), "app-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := NewSubscriberDecoder(body)
+	defer dec.Close()
+	var ids []string
+	for {
+		s, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, s.AppUserID)
+	}
+	if len(ids) != 3 || ids[2] != "user-3" {
+		t.Fatalf("unexpected subscribers %v", ids)
+	}
+}
+
+func TestStreamSubscribersCancel(t *testing.T) {
+	release := make(chan struct{})
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "{\"id\":\"s1\"}\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	})
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	body, err := c.StreamSubscribers(ctx, "app-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := NewSubscriberDecoder(body)
+	defer dec.Close()
+	if _, err := dec.Next(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := dec.Next(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the stream to end with context.Canceled, got %v", err)
+	}
+}
+
+func TestStreamSubscribersError(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	defer srv.Close()
+
+	if _, err := c.StreamSubscribers(context.Background(), "app-1"); !hasStatus(err, http.StatusForbidden) {
+		t.Fatalf("expected a 403 error, got %v", err)
+	}
+}
+
+func TestStreamSubscribersHoldsSlotUntilClosed(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/apps/app-1/subscribers/export" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, "{\"id\":\"s1\"}\n")
+	})
+	defer srv.Close()
+	WithMaxConcurrency(1)(c)
+	WithCircuitBreaker(1, time.Hour)(c)
+
+	body, err := c.StreamSubscribers(context.Background(), "app-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := c.InFlight(); n != 1 {
+		t.Fatalf("expected the open stream to be in flight, got %d", n)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.ListAppsContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the stream to hold the only slot, got %v", err)
+	}
+	body.Close()
+	body.Close()
+	if n := c.InFlight(); n != 0 {
+		t.Fatalf("expected the slot released on close, got %d in flight", n)
+	}
+
+	if _, err := c.ListProducts("app-1"); err == nil {
+		t.Fatal("expected the 502 to fail")
+	}
+	if _, err := c.StreamSubscribers(context.Background(), "app-1"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the open breaker to reject the stream, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package opencat
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Below this many remaining requests the throttle spreads the rest evenly
+// over the time left in the window instead of spending them at once.
+const throttleLowWater = 5
+
+type throttle struct {
+	mu        sync.Mutex
+	known     bool
+	remaining int
+	reset     time.Time
+}
+
+// wait blocks until the server's rate limit allows another request, based on
+// the last X-RateLimit headers seen, and takes one request from the budget.
+func (t *throttle) wait(ctx context.Context) error {
+	t.mu.Lock()
+	var delay time.Duration
+	if t.known {
+		untilReset := time.Until(t.reset)
+		switch {
+		case untilReset <= 0:
+			t.known = false
+		case t.remaining <= 0:
+			delay = untilReset
+			t.known = false
+		case t.remaining < throttleLowWater:
+			delay = untilReset / time.Duration(t.remaining+1)
+			t.remaining--
+		default:
+			t.remaining--
+		}
+	}
+	t.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *throttle) update(h http.Header) {
+	remaining, reset, ok := parseRateLimit(h)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	t.known, t.remaining, t.reset = true, remaining, reset
+	t.mu.Unlock()
+}
+
+// parseRateLimit reads X-RateLimit-Remaining and X-RateLimit-Reset. The reset
+// may be a Unix time or a number of seconds from now.
+func parseRateLimit(h http.Header) (remaining int, reset time.Time, ok bool) {
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	secs, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	if secs > 1e9 {
+		reset = time.Unix(secs, 0)
+	} else {
+		reset = time.Now().Add(time.Duration(secs) * time.Second)
+	}
+	return remaining, reset, true
+}
@@ -0,0 +1,54 @@
+package opencat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveThrottleWaitsForReset(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "60")
+		json.NewEncoder(w).Encode([]App{})
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "test-key", WithAdaptiveThrottle())
+	if _, err := c.ListApps(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.ListAppsContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected to wait for the reset until the deadline, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected the throttled request not to be sent, got %d calls", calls)
+	}
+}
+
+func TestThrottlePacesLowBudget(t *testing.T) {
+	th := &throttle{known: true, remaining: 1, reset: time.Now().Add(40 * time.Millisecond)}
+	start := time.Now()
+	if err := th.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Fatalf("expected a paced wait, returned after %s", elapsed)
+	}
+
+	th = &throttle{known: true, remaining: 100, reset: time.Now().Add(time.Minute)}
+	start = time.Now()
+	th.wait(context.Background())
+	if time.Since(start) > 5*time.Millisecond || th.remaining != 99 {
+		t.Fatalf("expected no wait with ample budget, remaining %d", th.remaining)
+	}
+}
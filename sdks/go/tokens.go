@@ -0,0 +1,214 @@
+package opencat
+
+import (
+	"container/list"
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Refresh point, as a fraction of token lifetime, used when
+// WithEntitlementTokenCache is given one outside (0, 1).
+const defaultTokenRefreshAt = 0.8
+
+// Floor on the delay before a background refresh, so a token issued with a
+// very short lifetime cannot make the cache refresh in a tight loop.
+var minTokenRefreshDelay = time.Second
+
+// Subscribers whose tokens a client caches at once; the least recently used
+// subscriber is evicted beyond this.
+const tokenCacheSubscribers = 4096
+
+type tokenCache struct {
+	refreshAt float64
+	max       int
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	closed  bool
+}
+
+type tokenEntry struct {
+	appUserID string
+	token     *EntitlementToken
+	timer     *time.Timer
+	// read records whether the token was served since it was last stored. A
+	// token nobody reads is not refreshed again until it is read.
+	read bool
+}
+
+func newTokenCache(refreshAt float64) *tokenCache {
+	return &tokenCache{refreshAt: refreshAt, max: tokenCacheSubscribers, lru: list.New(), entries: map[string]*list.Element{}}
+}
+
+// removeLocked drops el from the cache and stops its refresh. tc.mu must be
+// held.
+func (tc *tokenCache) removeLocked(el *list.Element) {
+	entry := el.Value.(*tokenEntry)
+	if entry.timer != nil {
+		entry.timer.Stop()
+	}
+	tc.lru.Remove(el)
+	delete(tc.entries, entry.appUserID)
+}
+
+// GetEntitlementToken returns a signed token listing the subscriber's active
+// entitlements, which can be checked offline until it expires. With
+// WithEntitlementTokenCache, an unexpired cached token is returned without a
+// request.
+func (c *Client) GetEntitlementToken(appUserID string, opts ...CallOption) (*EntitlementToken, error) {
+	return c.GetEntitlementTokenContext(context.Background(), appUserID, opts...)
+}
+
+func (c *Client) GetEntitlementTokenContext(ctx context.Context, appUserID string, opts ...CallOption) (*EntitlementToken, error) {
+	if tc := c.tokens; tc != nil {
+		tc.mu.Lock()
+		if el, ok := tc.entries[appUserID]; ok {
+			entry := el.Value.(*tokenEntry)
+			if time.Now().Before(entry.token.ExpiresAt) {
+				tc.lru.MoveToFront(el)
+				entry.read = true
+				if entry.timer == nil {
+					c.scheduleTokenRefreshLocked(entry)
+				}
+				tc.mu.Unlock()
+				return entry.token, nil
+			}
+		}
+		tc.mu.Unlock()
+	}
+	return c.RefreshEntitlementTokenContext(ctx, appUserID, opts...)
+}
+
+// RefreshEntitlementToken fetches a new token for the subscriber, replacing
+// any cached one.
+func (c *Client) RefreshEntitlementToken(appUserID string, opts ...CallOption) (*EntitlementToken, error) {
+	return c.RefreshEntitlementTokenContext(context.Background(), appUserID, opts...)
+}
+
+func (c *Client) RefreshEntitlementTokenContext(ctx context.Context, appUserID string, opts ...CallOption) (*EntitlementToken, error) {
+	token, err := c.fetchEntitlementToken(ctx, appUserID, opts)
+	if err != nil {
+		return nil, err
+	}
+	if tc := c.tokens; tc != nil {
+		tc.mu.Lock()
+		c.storeTokenLocked(appUserID, token, true)
+		tc.mu.Unlock()
+	}
+	return token, nil
+}
+
+// InvalidateEntitlementToken drops the subscriber's cached token and stops
+// refreshing it.
+func (c *Client) InvalidateEntitlementToken(appUserID string) {
+	tc := c.tokens
+	if tc == nil {
+		return
+	}
+	tc.mu.Lock()
+	if el, ok := tc.entries[appUserID]; ok {
+		tc.removeLocked(el)
+	}
+	tc.mu.Unlock()
+}
+
+// Close stops the client's background work: cached entitlement tokens are
+// still served until they expire but are no longer refreshed. The client
+// remains usable for requests.
+func (c *Client) Close() {
+	tc := c.tokens
+	if tc == nil {
+		return
+	}
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.closed = true
+	for el := tc.lru.Front(); el != nil; el = el.Next() {
+		if entry := el.Value.(*tokenEntry); entry.timer != nil {
+			entry.timer.Stop()
+			entry.timer = nil
+		}
+	}
+}
+
+func (c *Client) fetchEntitlementToken(ctx context.Context, appUserID string, opts []CallOption) (*EntitlementToken, error) {
+	var result EntitlementToken
+	err := c.request(ctx, "GET", "/v1/subscribers/"+url.PathEscape(appUserID)+"/entitlements/token", nil, nil, &result, opts)
+	if err != nil {
+		return nil, err
+	}
+	if result.IssuedAt.IsZero() {
+		result.IssuedAt = time.Now()
+	}
+	return &result, nil
+}
+
+// storeTokenLocked caches token, evicting the least recently used subscriber
+// if the cache is full, and schedules its background refresh. read reports
+// whether the token has already been served. tc.mu must be held.
+func (c *Client) storeTokenLocked(appUserID string, token *EntitlementToken, read bool) {
+	tc := c.tokens
+	if el, ok := tc.entries[appUserID]; ok {
+		tc.removeLocked(el)
+	}
+	entry := &tokenEntry{appUserID: appUserID, token: token, read: read}
+	tc.entries[appUserID] = tc.lru.PushFront(entry)
+	for tc.lru.Len() > tc.max {
+		tc.removeLocked(tc.lru.Back())
+	}
+	c.scheduleTokenRefreshLocked(entry)
+}
+
+// scheduleTokenRefreshLocked arms entry's background refresh at the
+// configured fraction of its token's lifetime. tc.mu must be held.
+func (c *Client) scheduleTokenRefreshLocked(entry *tokenEntry) {
+	tc := c.tokens
+	if tc.closed {
+		return
+	}
+	token := entry.token
+	lifetime := token.ExpiresAt.Sub(token.IssuedAt)
+	delay := time.Until(token.IssuedAt.Add(time.Duration(float64(lifetime) * tc.refreshAt)))
+	entry.timer = time.AfterFunc(max(delay, minTokenRefreshDelay), func() { c.refreshTokenInBackground(entry) })
+}
+
+// refreshTokenInBackground replaces entry's token if it was read since it
+// was stored. An unread entry is left to expire without a timer; reading it
+// again schedules a refresh.
+func (c *Client) refreshTokenInBackground(entry *tokenEntry) {
+	tc := c.tokens
+	tc.mu.Lock()
+	if el, ok := tc.entries[entry.appUserID]; !ok || el.Value != entry || tc.closed {
+		tc.mu.Unlock()
+		return
+	}
+	if !entry.read {
+		entry.timer = nil
+		tc.mu.Unlock()
+		return
+	}
+	entry.read = false
+	tc.mu.Unlock()
+
+	token, err := c.fetchEntitlementToken(context.Background(), entry.appUserID, nil)
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if el, ok := tc.entries[entry.appUserID]; !ok || el.Value != entry || tc.closed {
+		return
+	}
+	if err == nil {
+		c.storeTokenLocked(entry.appUserID, token, entry.read)
+		return
+	}
+	// Keep serving the current token and try again while it is still valid.
+	if remaining := time.Until(entry.token.ExpiresAt); remaining > 0 {
+		entry.read = true
+		entry.timer.Reset(max(remaining/4, minTokenRefreshDelay))
+	} else {
+		tc.removeLocked(tc.entries[entry.appUserID])
+	}
+}
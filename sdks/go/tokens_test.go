@@ -0,0 +1,139 @@
+package opencat
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEntitlementTokenCacheRefreshesInBackground(t *testing.T) {
+	defer func(d time.Duration) { minTokenRefreshDelay = d }(minTokenRefreshDelay)
+	minTokenRefreshDelay = time.Millisecond
+
+	var issued int32
+	srv := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/subscribers/user-1/entitlements/token" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		n := atomic.AddInt32(&issued, 1)
+		now := time.Now()
+		json.NewEncoder(w).Encode(EntitlementToken{
+			Token:     fmt.Sprintf("jwt-%d", n),
+			IssuedAt:  now,
+			ExpiresAt: now.Add(50 * time.Millisecond),
+		})
+	}
+	c, s := setupServer(t, srv)
+	defer s.Close()
+	WithEntitlementTokenCache(0.5)(c)
+	defer c.InvalidateEntitlementToken("user-1")
+
+	tok, err := c.GetEntitlementToken("user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := c.GetEntitlementToken("user-1"); again.Token != tok.Token {
+		t.Fatalf("expected cached token %s, got %s", tok.Token, again.Token)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&issued) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("token was not refreshed in the background")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for {
+		tok, _ := c.GetEntitlementToken("user-1")
+		if tok.Token != "jwt-1" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the refreshed token to be served")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	c.InvalidateEntitlementToken("user-1")
+	time.Sleep(10 * time.Millisecond) // let a refresh already in flight finish
+	n := atomic.LoadInt32(&issued)
+	time.Sleep(60 * time.Millisecond)
+	if atomic.LoadInt32(&issued) != n {
+		t.Fatal("expected refreshing to stop after invalidation")
+	}
+}
+
+func TestEntitlementTokenCacheStopsRefreshingIdleTokens(t *testing.T) {
+	defer func(d time.Duration) { minTokenRefreshDelay = d }(minTokenRefreshDelay)
+	minTokenRefreshDelay = time.Millisecond
+
+	var issued int32
+	c, s := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&issued, 1)
+		now := time.Now()
+		json.NewEncoder(w).Encode(EntitlementToken{
+			Token:     fmt.Sprintf("jwt-%d", n),
+			IssuedAt:  now,
+			ExpiresAt: now.Add(40 * time.Millisecond),
+		})
+	})
+	defer s.Close()
+	WithEntitlementTokenCache(0.5)(c)
+	defer c.Close()
+
+	if _, err := c.GetEntitlementToken("user-1"); err != nil {
+		t.Fatal(err)
+	}
+	// The fetched token was read, so it is refreshed once; the refreshed one
+	// is never read and must not be refreshed again.
+	time.Sleep(150 * time.Millisecond)
+	if n := atomic.LoadInt32(&issued); n != 2 {
+		t.Fatalf("expected 2 tokens issued for an idle subscriber, got %d", n)
+	}
+
+	// Reading the subscriber again resumes refreshing.
+	if _, err := c.GetEntitlementToken("user-1"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&issued) < 4 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected refreshing to resume after a read")
+		}
+		c.GetEntitlementToken("user-1")
+		time.Sleep(time.Millisecond)
+	}
+
+	c.Close()
+	time.Sleep(10 * time.Millisecond) // let a refresh already in flight finish
+	n := atomic.LoadInt32(&issued)
+	time.Sleep(60 * time.Millisecond)
+	if atomic.LoadInt32(&issued) != n {
+		t.Fatal("expected refreshing to stop after Close")
+	}
+}
+
+func TestEntitlementTokenCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c, s := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(EntitlementToken{Token: r.URL.Path, ExpiresAt: time.Now().Add(time.Hour)})
+	})
+	defer s.Close()
+	WithEntitlementTokenCache(0)(c)
+	defer c.Close()
+	c.tokens.max = 2
+
+	for _, id := range []string{"user-1", "user-2", "user-1", "user-3"} {
+		if _, err := c.GetEntitlementToken(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := c.tokens.entries["user-2"]; ok {
+		t.Fatal("expected the least recently used subscriber to be evicted")
+	}
+	if len(c.tokens.entries) != 2 || c.tokens.lru.Len() != 2 {
+		t.Fatalf("expected 2 cached tokens, got %d", len(c.tokens.entries))
+	}
+}
@@ -0,0 +1,109 @@
+package opencat
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const maxEntitlementNameLen = 64
+
+var entitlementNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ValidateEntitlementName checks a name against a recommended convention for
+// entitlement names: 1 to 64 characters, ASCII letters, digits, '_', '-' and
+// '.', starting with a letter or digit. It is an opt-in check for callers
+// who want to keep names uniform. The server does not enforce it: it accepts
+// any name, such as "Pro Plan", requiring only that names be unique within
+// an app, and CreateEntitlement and UpdateEntitlement send names unchecked
+// unless given WithValidateName.
+func ValidateEntitlementName(name string) error {
+	if name == "" {
+		return fmt.Errorf("opencat: entitlement name is required")
+	}
+	if len(name) > maxEntitlementNameLen {
+		return fmt.Errorf("opencat: entitlement name is longer than %d characters", maxEntitlementNameLen)
+	}
+	if !entitlementNamePattern.MatchString(name) {
+		return fmt.Errorf("opencat: invalid entitlement name %q", name)
+	}
+	return nil
+}
+
+const maxAppUserIDLen = 256
+
+// NormalizeAppUserID trims surrounding whitespace from an app_user_id and
+// checks that the result is usable in a request path. Accepted IDs are 1 to
+// 256 bytes of printable characters other than whitespace and '/', and are
+// not "." or "..". Anything else, such as an embedded newline or
+// zero-width space from a copy-paste, is rejected instead of being escaped
+// into an ID that silently misses the subscriber.
+func NormalizeAppUserID(raw string) (string, error) {
+	id := strings.TrimSpace(raw)
+	if id == "" {
+		return "", fmt.Errorf("opencat: app_user_id is empty")
+	}
+	if len(id) > maxAppUserIDLen {
+		return "", fmt.Errorf("opencat: app_user_id is longer than %d bytes", maxAppUserIDLen)
+	}
+	if id == "." || id == ".." {
+		return "", fmt.Errorf("opencat: invalid app_user_id %q", id)
+	}
+	for _, r := range id {
+		if r == '/' || r == unicode.ReplacementChar || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return "", fmt.Errorf("opencat: app_user_id %q contains invalid character %q", id, r)
+		}
+	}
+	return id, nil
+}
+
+// ValidateEmail checks that email is a bare address such as
+// "jane@example.com", without a display name or angle brackets.
+func ValidateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return fmt.Errorf("opencat: invalid email %q", email)
+	}
+	return nil
+}
+
+var (
+	googleOrderIDPattern       = regexp.MustCompile(`^GPA\.\d{4}-\d{4}-\d{4}-\d{5}(\.\.\d+)?$`)
+	googlePurchaseTokenPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{100,}$`)
+	appleTransactionIDPattern  = regexp.MustCompile(`^\d+$`)
+)
+
+var stripeIDPrefixes = []string{"pi_", "ch_", "in_", "sub_", "cs_", "py_"}
+
+// InferStore guesses which store issued a transaction ID from its format:
+//
+//   - Stripe object IDs (pi_, ch_, in_, sub_, cs_, py_ prefixes): confident.
+//   - Google Play order IDs (GPA.1234-5678-9012-34567, optionally with a
+//     ..N renewal suffix): confident.
+//   - Apple transaction IDs (15 to 19 digits): confident. Other all-digit IDs
+//     are reported as Apple without confidence.
+//   - Long opaque tokens of letters, digits, '.', '_' and '-' look like Play
+//     purchase tokens and are reported as Google without confidence.
+//
+// Amazon receipt IDs have no distinctive format and are never inferred.
+// Store formats are not guaranteed, so treat even a confident answer as a
+// fallback for feeds that lack a store field, not as validation.
+func InferStore(storeTransactionID string) (Store, bool) {
+	id := strings.TrimSpace(storeTransactionID)
+	for _, prefix := range stripeIDPrefixes {
+		if strings.HasPrefix(id, prefix) && len(id) > len(prefix) {
+			return StoreStripe, true
+		}
+	}
+	switch {
+	case googleOrderIDPattern.MatchString(id):
+		return StoreGoogle, true
+	case appleTransactionIDPattern.MatchString(id):
+		return StoreApple, len(id) >= 15 && len(id) <= 19
+	case googlePurchaseTokenPattern.MatchString(id):
+		return StoreGoogle, false
+	}
+	return "", false
+}
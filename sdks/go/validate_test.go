@@ -0,0 +1,119 @@
+package opencat
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidateEntitlementName(t *testing.T) {
+	for _, name := range []string{"pro", "Premium_2", "team.seats", "a-b"} {
+		if err := ValidateEntitlementName(name); err != nil {
+			t.Errorf("%q: unexpected error %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "_pro", "pro plan", "pro/plan", strings.Repeat("a", 65)} {
+		if err := ValidateEntitlementName(name); err == nil {
+			t.Errorf("%q: expected error", name)
+		}
+	}
+}
+
+func TestCreateEntitlementSendsNameUnchecked(t *testing.T) {
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		json.NewEncoder(w).Encode(Entitlement{ID: "e1", Name: body["name"]})
+	})
+	defer srv.Close()
+
+	e, err := c.CreateEntitlement("app-1", "Pro Plan", nil)
+	if err != nil || e.Name != "Pro Plan" {
+		t.Fatalf("expected a name the server accepts to be sent, got %+v, %v", e, err)
+	}
+	name := "Pro Plan"
+	if _, err := c.UpdateEntitlement("app-1", "e1", EntitlementUpdate{Name: &name}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithValidateName(t *testing.T) {
+	var calls int
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		json.NewEncoder(w).Encode(Entitlement{ID: "e1", Name: "pro"})
+	})
+	defer srv.Close()
+
+	if _, err := c.CreateEntitlement("app-1", "Pro Plan", nil, WithValidateName()); err == nil {
+		t.Fatal("expected an invalid name to be rejected")
+	}
+	name := "Pro Plan"
+	if _, err := c.UpdateEntitlement("app-1", "e1", EntitlementUpdate{Name: &name}, WithValidateName()); err == nil {
+		t.Fatal("expected an invalid name to be rejected")
+	}
+	if calls != 0 {
+		t.Fatalf("expected no requests for invalid names, got %d", calls)
+	}
+	if _, err := c.CreateEntitlement("app-1", "pro", nil, WithValidateName()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UpdateEntitlement("app-1", "e1", EntitlementUpdate{}, WithValidateName()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNormalizeAppUserID(t *testing.T) {
+	for raw, want := range map[string]string{
+		"user-1":          "user-1",
+		"  user-1\n":      "user-1",
+		"auth0|abc@x.com": "auth0|abc@x.com",
+		"名前":              "名前",
+	} {
+		got, err := NormalizeAppUserID(raw)
+		if err != nil || got != want {
+			t.Errorf("NormalizeAppUserID(%q) = %q, %v; want %q", raw, got, err, want)
+		}
+	}
+	for _, raw := range []string{"", "   ", "a b", "a/b", "a\u200bb", "..", "a\x00b", strings.Repeat("a", 257)} {
+		if _, err := NormalizeAppUserID(raw); err == nil {
+			t.Errorf("NormalizeAppUserID(%q): expected error", raw)
+		}
+	}
+}
+
+func TestValidateEmail(t *testing.T) {
+	if err := ValidateEmail("jane@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	for _, email := range []string{"", "jane", "jane@", "Jane <jane@example.com>", " jane@example.com"} {
+		if err := ValidateEmail(email); err == nil {
+			t.Errorf("%q: expected error", email)
+		}
+	}
+}
+
+func TestInferStore(t *testing.T) {
+	cases := []struct {
+		id        string
+		store     Store
+		confident bool
+	}{
+		{"2000000123456789", StoreApple, true},
+		{"12345", StoreApple, false},
+		{"GPA.3372-1234-5678-90123", StoreGoogle, true},
+		{"GPA.3372-1234-5678-90123..2", StoreGoogle, true},
+		{strings.Repeat("aBc1.-_", 20), StoreGoogle, false},
+		{"sub_1OaBcDeFgHiJkL", StoreStripe, true},
+		{"pi_3OaBcDeFgHiJkL", StoreStripe, true},
+		{"amzn1.receipt.v1", "", false},
+		{"", "", false},
+	}
+	for _, tc := range cases {
+		store, confident := InferStore(tc.id)
+		if store != tc.store || confident != tc.confident {
+			t.Errorf("InferStore(%q) = %q, %v; want %q, %v", tc.id, store, confident, tc.store, tc.confident)
+		}
+	}
+}
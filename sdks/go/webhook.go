@@ -0,0 +1,269 @@
+package opencat
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Webhook payload schema versions. Events without a schema_version predate
+// versioning and use SchemaV1.
+const (
+	SchemaV1 = "1"
+	SchemaV2 = "2"
+)
+
+// SignatureHeader carries the HMAC of a webhook body, formatted as
+// "<algorithm>=<hex digest>", e.g. "sha512=9f86...".
+const SignatureHeader = "X-Webhook-Signature"
+
+// SigningAlgorithm is the HMAC hash an endpoint's deliveries are signed with.
+type SigningAlgorithm string
+
+const (
+	SigningSHA256 SigningAlgorithm = "sha256"
+	SigningSHA512 SigningAlgorithm = "sha512"
+)
+
+func (a SigningAlgorithm) hash() func() hash.Hash {
+	switch a {
+	case SigningSHA256:
+		return sha256.New
+	case SigningSHA512:
+		return sha512.New
+	}
+	return nil
+}
+
+var (
+	ErrInvalidWebhookSecret    = errors.New("opencat: invalid webhook secret")
+	ErrInvalidWebhookSignature = errors.New("opencat: invalid webhook signature")
+	ErrUnknownSigningAlgorithm = errors.New("opencat: unknown webhook signing algorithm")
+	ErrUnknownSchemaVersion    = errors.New("opencat: unknown webhook schema version")
+	ErrWebhookAppUnknown       = errors.New("opencat: webhook delivery does not name its app")
+	ErrEventTypeMismatch       = errors.New("opencat: event is of a different type")
+)
+
+// PayloadV1 is the store notification, forwarded as received.
+type PayloadV1 struct {
+	Notification json.RawMessage
+}
+
+// PayloadV2 is the store-independent payload.
+type PayloadV2 struct {
+	AppID          string  `json:"app_id"`
+	AppUserID      string  `json:"app_user_id"`
+	ProductID      string  `json:"product_id"`
+	Store          string  `json:"store"`
+	TransactionID  string  `json:"transaction_id"`
+	ExpirationDate *string `json:"expiration_date,omitempty"`
+}
+
+// ParseWebhook verifies a webhook delivery against the endpoint secret and
+// decodes its body. The server POSTs the event payload itself, not an Event,
+// so the result is the payload struct for the delivery's schema version:
+// *PayloadV2 when the body names its app, *PayloadV1 otherwise.
+func ParseWebhook(r *http.Request, secret string) (any, error) {
+	if !validWebhookSecret(r, secret) {
+		return nil, ErrInvalidWebhookSecret
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	return decodeWebhookPayload(body)
+}
+
+// ParseWebhookWithResolver is ParseWebhook for receivers shared by several
+// apps. It reads the app ID from the delivery, asks resolve for that app's
+// secret, and verifies the delivery against it. The app ID is untrusted until
+// verification succeeds: a delivery claiming another app fails unless it
+// carries that app's secret. Only schema version 2 deliveries name their app.
+func ParseWebhookWithResolver(r *http.Request, resolve func(appID string) (string, error)) (*PayloadV2, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := decodeWebhookPayload(body)
+	if err != nil {
+		return nil, err
+	}
+	v2, ok := payload.(*PayloadV2)
+	if !ok {
+		return nil, ErrWebhookAppUnknown
+	}
+	secret, err := resolve(v2.AppID)
+	if err != nil {
+		return nil, err
+	}
+	if !validWebhookSecret(r, secret) {
+		return nil, ErrInvalidWebhookSecret
+	}
+	return v2, nil
+}
+
+func validWebhookSecret(r *http.Request, secret string) bool {
+	got := r.Header.Get("X-Webhook-Secret")
+	return secret != "" && subtle.ConstantTimeCompare([]byte(got), []byte(secret)) == 1
+}
+
+// decodeWebhookPayload decodes a delivery body. Store notifications
+// forwarded as received (version 1) never carry app_id, so its presence is
+// what marks a version 2 payload.
+func decodeWebhookPayload(body []byte) (any, error) {
+	var probe struct {
+		AppID string `json:"app_id"`
+	}
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return nil, err
+	}
+	if probe.AppID == "" {
+		return &PayloadV1{Notification: json.RawMessage(body)}, nil
+	}
+	var p PayloadV2
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SignWebhookPayload returns the SignatureHeader value for payload signed
+// with secret using alg.
+func SignWebhookPayload(alg SigningAlgorithm, payload []byte, secret string) (string, error) {
+	h := alg.hash()
+	if h == nil {
+		return "", fmt.Errorf("%w: %q", ErrUnknownSigningAlgorithm, alg)
+	}
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(payload)
+	return string(alg) + "=" + hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// VerifyWebhookSignature checks a SignatureHeader value against payload,
+// using the algorithm named in the header's prefix. Headers without a prefix
+// or with an algorithm this SDK does not know are rejected.
+func VerifyWebhookSignature(payload []byte, header, secret string) error {
+	alg, digest, ok := strings.Cut(header, "=")
+	if !ok {
+		return ErrInvalidWebhookSignature
+	}
+	h := SigningAlgorithm(alg).hash()
+	if h == nil {
+		return fmt.Errorf("%w: %q", ErrUnknownSigningAlgorithm, alg)
+	}
+	got, err := hex.DecodeString(digest)
+	if err != nil {
+		return ErrInvalidWebhookSignature
+	}
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(payload)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return ErrInvalidWebhookSignature
+	}
+	return nil
+}
+
+// DecodePayload decodes Payload into the struct for the event's schema
+// version: *PayloadV1 or *PayloadV2.
+func (e *Event) DecodePayload() (any, error) {
+	switch e.SchemaVersion {
+	case "", SchemaV1:
+		return &PayloadV1{Notification: json.RawMessage(e.Payload)}, nil
+	case SchemaV2:
+		var p PayloadV2
+		if err := json.Unmarshal([]byte(e.Payload), &p); err != nil {
+			return nil, err
+		}
+		return &p, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSchemaVersion, e.SchemaVersion)
+	}
+}
+
+// PurchaseEvent is the payload of an EventPurchase event.
+type PurchaseEvent struct {
+	PayloadV2
+	PriceMicros  int64  `json:"price_micros"`
+	Currency     string `json:"currency"`
+	PurchaseDate string `json:"purchase_date"`
+}
+
+// RenewalEvent is the payload of an EventRenewal event.
+type RenewalEvent struct {
+	PayloadV2
+	PriceMicros int64  `json:"price_micros"`
+	Currency    string `json:"currency"`
+}
+
+// CancellationEvent is the payload of an EventCancellation event. The
+// subscription stays active until ExpirationDate.
+type CancellationEvent struct {
+	PayloadV2
+	Reason *StatusReason `json:"reason,omitempty"`
+}
+
+// ExpirationEvent is the payload of an EventExpiration event.
+type ExpirationEvent struct {
+	PayloadV2
+}
+
+// AsPurchase decodes the payload of an EventPurchase event. It fails with
+// ErrEventTypeMismatch for other event types.
+func (e *Event) AsPurchase() (*PurchaseEvent, error) {
+	var p PurchaseEvent
+	if err := e.decodeAs(EventPurchase, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// AsRenewal decodes the payload of an EventRenewal event. It fails with
+// ErrEventTypeMismatch for other event types.
+func (e *Event) AsRenewal() (*RenewalEvent, error) {
+	var p RenewalEvent
+	if err := e.decodeAs(EventRenewal, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// AsCancellation decodes the payload of an EventCancellation event. It fails
+// with ErrEventTypeMismatch for other event types.
+func (e *Event) AsCancellation() (*CancellationEvent, error) {
+	var p CancellationEvent
+	if err := e.decodeAs(EventCancellation, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// AsExpiration decodes the payload of an EventExpiration event. It fails with
+// ErrEventTypeMismatch for other event types.
+func (e *Event) AsExpiration() (*ExpirationEvent, error) {
+	var p ExpirationEvent
+	if err := e.decodeAs(EventExpiration, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// decodeAs decodes a schema version 2 payload into p. Version 1 payloads are
+// store notifications with no common shape; use DecodePayload for those.
+func (e *Event) decodeAs(want EventType, p any) error {
+	if e.EventType != want {
+		return fmt.Errorf("%w: want %s, got %s", ErrEventTypeMismatch, want, e.EventType)
+	}
+	if e.SchemaVersion != SchemaV2 {
+		return fmt.Errorf("%w: typed payloads need version %s, got %q", ErrUnknownSchemaVersion, SchemaV2, e.SchemaVersion)
+	}
+	return json.Unmarshal([]byte(e.Payload), p)
+}
@@ -0,0 +1,178 @@
+package opencat
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyIDHeader names the server key that signed a webhook delivery, for
+// deliveries signed with SigningEd25519 or SigningRS256.
+const KeyIDHeader = "X-Webhook-Key-Id"
+
+// Asymmetric signing algorithms. Signatures are base64 (standard encoding)
+// after the algorithm prefix, e.g. "ed25519=MEUCIQ...".
+const (
+	SigningEd25519 SigningAlgorithm = "ed25519"
+	SigningRS256   SigningAlgorithm = "rs256"
+)
+
+var ErrUnknownWebhookKey = errors.New("opencat: unknown webhook signing key")
+
+const (
+	defaultWebhookKeyTTL = time.Hour
+	// Minimum time between fetches triggered by an unknown key ID, so forged
+	// key IDs cannot make every delivery hit the server.
+	webhookKeyRefetchInterval = 30 * time.Second
+)
+
+type webhookKeyCache struct {
+	ttl        time.Duration
+	minRefetch time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch {
+	case k.Kty == "OKP" && k.Crv == "Ed25519":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("opencat: invalid Ed25519 key %q", k.Kid)
+		}
+		return ed25519.PublicKey(x), nil
+	case k.Kty == "RSA":
+		n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+		e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+		if err1 != nil || err2 != nil || len(n) == 0 || len(e) == 0 {
+			return nil, fmt.Errorf("opencat: invalid RSA key %q", k.Kid)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	}
+	return nil, fmt.Errorf("opencat: unsupported key type %q for key %q", k.Kty, k.Kid)
+}
+
+// GetWebhookPublicKey fetches the key the server currently signs webhook
+// deliveries with. The server lists the current key first in its key set.
+func (c *Client) GetWebhookPublicKey(opts ...CallOption) (crypto.PublicKey, error) {
+	return c.GetWebhookPublicKeyContext(context.Background(), opts...)
+}
+
+func (c *Client) GetWebhookPublicKeyContext(ctx context.Context, opts ...CallOption) (crypto.PublicKey, error) {
+	keys, current, err := c.fetchWebhookKeys(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if current == "" {
+		return nil, ErrUnknownWebhookKey
+	}
+	return keys[current], nil
+}
+
+func (c *Client) fetchWebhookKeys(ctx context.Context, opts []CallOption) (map[string]crypto.PublicKey, string, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := c.request(ctx, "GET", "/v1/webhooks/jwks", nil, nil, &set, opts); err != nil {
+		return nil, "", err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	current := ""
+	for _, k := range set.Keys {
+		pub, err := k.publicKey()
+		if err != nil {
+			return nil, "", err
+		}
+		if current == "" {
+			current = k.Kid
+		}
+		keys[k.Kid] = pub
+	}
+	return keys, current, nil
+}
+
+// VerifyWebhookSignature checks an asymmetrically signed webhook delivery
+// against the server's public key named by KeyIDHeader. Keys are cached for
+// the TTL set with WithWebhookKeyTTL (an hour by default); a key ID missing
+// from the cache triggers a refetch, so rotated keys are picked up. Use the
+// package-level VerifyWebhookSignature for shared-secret signatures.
+func (c *Client) VerifyWebhookSignature(payload []byte, header http.Header) error {
+	return c.VerifyWebhookSignatureContext(context.Background(), payload, header)
+}
+
+func (c *Client) VerifyWebhookSignatureContext(ctx context.Context, payload []byte, header http.Header) error {
+	alg, encoded, ok := strings.Cut(header.Get(SignatureHeader), "=")
+	if !ok {
+		return ErrInvalidWebhookSignature
+	}
+	sig, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return ErrInvalidWebhookSignature
+	}
+	key, err := c.webhookKey(ctx, header.Get(KeyIDHeader))
+	if err != nil {
+		return err
+	}
+
+	switch SigningAlgorithm(alg) {
+	case SigningEd25519:
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(pub, payload, sig) {
+			return ErrInvalidWebhookSignature
+		}
+	case SigningRS256:
+		pub, ok := key.(*rsa.PublicKey)
+		digest := sha256.Sum256(payload)
+		if !ok || rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) != nil {
+			return ErrInvalidWebhookSignature
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownSigningAlgorithm, alg)
+	}
+	return nil
+}
+
+func (c *Client) webhookKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	kc := c.webhookKeys
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	age := time.Since(kc.fetchedAt)
+	key, ok := kc.keys[kid]
+	if ok && age < kc.ttl {
+		return key, nil
+	}
+	if ok || kc.keys == nil || age >= kc.minRefetch {
+		keys, _, err := c.fetchWebhookKeys(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		kc.keys, kc.fetchedAt = keys, time.Now()
+		key, ok = keys[kid]
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownWebhookKey, kid)
+	}
+	return key, nil
+}
@@ -0,0 +1,88 @@
+package opencat
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+type testSigningKey struct {
+	kid  string
+	priv ed25519.PrivateKey
+}
+
+func newTestSigningKey(t *testing.T, kid string) testSigningKey {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testSigningKey{kid: kid, priv: priv}
+}
+
+func (k testSigningKey) jwk() map[string]string {
+	pub := k.priv.Public().(ed25519.PublicKey)
+	return map[string]string{"kty": "OKP", "crv": "Ed25519", "kid": k.kid, "x": base64.RawURLEncoding.EncodeToString(pub)}
+}
+
+func (k testSigningKey) sign(payload []byte) http.Header {
+	h := http.Header{}
+	h.Set(SignatureHeader, "ed25519="+base64.StdEncoding.EncodeToString(ed25519.Sign(k.priv, payload)))
+	h.Set(KeyIDHeader, k.kid)
+	return h
+}
+
+func TestVerifyWebhookSignatureWithServerKeys(t *testing.T) {
+	oldKey, newKey := newTestSigningKey(t, "k1"), newTestSigningKey(t, "k2")
+	current := []testSigningKey{oldKey}
+	var fetches int32
+	c, srv := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/webhooks/jwks" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		atomic.AddInt32(&fetches, 1)
+		var keys []map[string]string
+		for _, k := range current {
+			keys = append(keys, k.jwk())
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	})
+	defer srv.Close()
+	c.webhookKeys.minRefetch = 0
+
+	payload := []byte(`{"id":"ev1"}`)
+	for i := 0; i < 2; i++ {
+		if err := c.VerifyWebhookSignature(payload, oldKey.sign(payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fetches != 1 {
+		t.Fatalf("expected keys to be cached, got %d fetches", fetches)
+	}
+
+	current = []testSigningKey{newKey, oldKey}
+	if err := c.VerifyWebhookSignature(payload, newKey.sign(payload)); err != nil {
+		t.Fatalf("expected rotated key to be fetched: %v", err)
+	}
+	pub, err := c.GetWebhookPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !newKey.priv.Public().(ed25519.PublicKey).Equal(pub) {
+		t.Fatal("expected current key to be the rotated key")
+	}
+
+	tampered := oldKey.sign(payload)
+	if err := c.VerifyWebhookSignature([]byte(`{"id":"ev2"}`), tampered); !errors.Is(err, ErrInvalidWebhookSignature) {
+		t.Fatalf("expected invalid signature, got %v", err)
+	}
+	unknown := newTestSigningKey(t, "k3")
+	if err := c.VerifyWebhookSignature(payload, unknown.sign(payload)); !errors.Is(err, ErrUnknownWebhookKey) {
+		t.Fatalf("expected unknown key, got %v", err)
+	}
+}
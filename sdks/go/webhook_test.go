@@ -0,0 +1,148 @@
+package opencat
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// delivery builds a request the way the server's delivery worker sends it:
+// the raw event payload, authenticated only by X-Webhook-Secret.
+func delivery(payload, secret string) *http.Request {
+	r := httptest.NewRequest("POST", "/hook", strings.NewReader(payload))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Webhook-Secret", secret)
+	return r
+}
+
+func TestParseWebhookDispatchesOnSchemaVersion(t *testing.T) {
+	payload, err := ParseWebhook(delivery(`{"app_id":"app-1","product_id":"p1","store":"apple"}`, "sec"), "sec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, ok := payload.(*PayloadV2)
+	if !ok || v2.AppID != "app-1" || v2.ProductID != "p1" {
+		t.Fatalf("unexpected payload %#v", payload)
+	}
+}
+
+func TestParseWebhookDefaultsToV1(t *testing.T) {
+	notification := `{"signedPayload":"eyJhbGciOiJFUzI1NiJ9.e30.sig"}`
+	payload, err := ParseWebhook(delivery(notification, "sec"), "sec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1, ok := payload.(*PayloadV1)
+	if !ok {
+		t.Fatalf("expected *PayloadV1, got %#v", payload)
+	}
+	if string(v1.Notification) != notification {
+		t.Fatalf("expected the notification as received, got %s", v1.Notification)
+	}
+}
+
+func TestParseWebhookRejectsBadSecret(t *testing.T) {
+	r := httptest.NewRequest("POST", "/hook", strings.NewReader(`{}`))
+	r.Header.Set("X-Webhook-Secret", "wrong")
+
+	if _, err := ParseWebhook(r, "sec"); !errors.Is(err, ErrInvalidWebhookSecret) {
+		t.Fatalf("expected ErrInvalidWebhookSecret, got %v", err)
+	}
+}
+
+func TestDecodePayloadUnknownVersion(t *testing.T) {
+	e := &Event{SchemaVersion: "99", Payload: "{}"}
+	if _, err := e.DecodePayload(); !errors.Is(err, ErrUnknownSchemaVersion) {
+		t.Fatalf("expected ErrUnknownSchemaVersion, got %v", err)
+	}
+}
+
+func TestTypedEventPayloads(t *testing.T) {
+	purchase := &Event{EventType: EventPurchase, SchemaVersion: SchemaV2,
+		Payload: `{"app_user_id":"user-1","product_id":"pro","store":"apple","price_micros":9990000,"currency":"USD"}`}
+	p, err := purchase.AsPurchase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ProductID != "pro" || p.Store != "apple" || p.PriceMicros != 9990000 {
+		t.Fatalf("unexpected purchase %+v", p)
+	}
+	if _, err := purchase.AsRenewal(); !errors.Is(err, ErrEventTypeMismatch) {
+		t.Fatalf("expected ErrEventTypeMismatch, got %v", err)
+	}
+
+	cancel := &Event{EventType: EventCancellation, SchemaVersion: SchemaV2,
+		Payload: `{"expiration_date":"2026-05-01T00:00:00Z","reason":"billing_failure"}`}
+	c, err := cancel.AsCancellation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *c.ExpirationDate != "2026-05-01T00:00:00Z" || !c.Reason.Involuntary() {
+		t.Fatalf("unexpected cancellation %+v", c)
+	}
+
+	v1 := &Event{EventType: EventExpiration, Payload: `{"notificationType":"EXPIRED"}`}
+	if _, err := v1.AsExpiration(); !errors.Is(err, ErrUnknownSchemaVersion) {
+		t.Fatalf("expected version 1 payloads to be rejected, got %v", err)
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	payload := []byte(`{"id":"ev1"}`)
+	for _, alg := range []SigningAlgorithm{SigningSHA256, SigningSHA512} {
+		header, err := SignWebhookPayload(alg, payload, "sec")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(header, string(alg)+"=") {
+			t.Fatalf("expected %s prefix, got %q", alg, header)
+		}
+		if err := VerifyWebhookSignature(payload, header, "sec"); err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if err := VerifyWebhookSignature(payload, header, "other"); !errors.Is(err, ErrInvalidWebhookSignature) {
+			t.Fatalf("%s: expected invalid signature, got %v", alg, err)
+		}
+	}
+
+	if err := VerifyWebhookSignature(payload, "md5=abcd", "sec"); !errors.Is(err, ErrUnknownSigningAlgorithm) {
+		t.Fatalf("expected unknown algorithm, got %v", err)
+	}
+	if err := VerifyWebhookSignature(payload, "abcd", "sec"); !errors.Is(err, ErrInvalidWebhookSignature) {
+		t.Fatalf("expected invalid signature for missing prefix, got %v", err)
+	}
+}
+
+func TestParseWebhookWithResolver(t *testing.T) {
+	secrets := map[string]string{"app-1": "sec1", "app-2": "sec2"}
+	resolve := func(appID string) (string, error) {
+		if s, ok := secrets[appID]; ok {
+			return s, nil
+		}
+		return "", errors.New("unknown app")
+	}
+	forApp := func(appID, secret string) *http.Request {
+		return delivery(`{"app_id":"`+appID+`","product_id":"p1"}`, secret)
+	}
+
+	payload, err := ParseWebhookWithResolver(forApp("app-2", "sec2"), resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.AppID != "app-2" || payload.ProductID != "p1" {
+		t.Fatalf("unexpected payload %+v", payload)
+	}
+	if _, err := ParseWebhookWithResolver(forApp("app-2", "sec1"), resolve); !errors.Is(err, ErrInvalidWebhookSecret) {
+		t.Fatalf("expected another app's secret to be rejected, got %v", err)
+	}
+	if _, err := ParseWebhookWithResolver(forApp("app-3", "sec1"), resolve); err == nil {
+		t.Fatal("expected unresolvable app to be rejected")
+	}
+
+	v1 := delivery(`{"message":{"data":"e30="},"subscription":"projects/p/subscriptions/s"}`, "sec1")
+	if _, err := ParseWebhookWithResolver(v1, resolve); !errors.Is(err, ErrWebhookAppUnknown) {
+		t.Fatalf("expected v1 delivery to be rejected, got %v", err)
+	}
+}